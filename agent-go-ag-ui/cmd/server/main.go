@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"agent-go-ag-ui/internal/agent"
+	"agent-go-ag-ui/internal/agui_adapter"
+	"agent-go-ag-ui/internal/config"
+	"agent-go-ag-ui/internal/server"
+	"agent-go-ag-ui/internal/session"
+	"agent-go-ag-ui/internal/transport"
+	"agent-go-ag-ui/internal/transport/connectrpc"
+	"agent-go-ag-ui/internal/transport/sse"
+)
+
+// shutdownTimeout bounds how long in-flight requests get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// shutdownNotice is how long before the shutdown deadline the remaining runs are interrupted
+const shutdownNotice = 2 * time.Second
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Custom tools are added with agent.RegisterTool before the agents are built (e.g. from an
+	// init function), then enabled by name in ENABLED_TOOLS or AGENTS_CONFIG
+
+	// Create the ADK agents, sharing one genai client across models
+	agentFactory, err := agent.NewFactory(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create genai client: %v", err)
+	}
+	agents, err := agentFactory.Registry(ctx)
+	if err != nil {
+		log.Fatalf("Failed to create agents: %v", err)
+	}
+
+	// Shared components
+	sessionMgr := session.NewManager(session.RetryPolicy{
+		Attempts: cfg.SessionRetryAttempts,
+		Backoff:  cfg.SessionRetryBackoff,
+	}, cfg.SessionUserIsolation)
+
+	// Post-processors run in order on buffered responses; add custom filters here
+	var postProcessor agui_adapter.PostProcessor
+	if cfg.BufferResponse {
+		postProcessor = agui_adapter.PostProcessorChain{
+			agui_adapter.NewPatternFilter(cfg.ResponseStripPatterns),
+			agui_adapter.NewWordFilter(cfg.ResponseBlocklist),
+		}
+	}
+
+	// Request middleware runs in order before each run; add preprocessors (e.g. PII scrubbing) here
+	preprocessor := agui_adapter.RequestMiddlewareChain{}
+
+	// The authorizer decides per run whether the user may use the agent and thread; plug RBAC in here
+	var authorizer agui_adapter.Authorizer = agui_adapter.AllowAllAuthorizer{}
+
+	// The moderator checks user input before the model is called; plug a moderation service in here
+	var moderator agui_adapter.Moderator = agui_adapter.NoopModerator{}
+
+	adapter := agui_adapter.NewAGUIAdapter(cfg, agents, sessionMgr, preprocessor, postProcessor, authorizer, moderator)
+	stateMgr := transport.NewStateManager(cfg.MaxStateBytes)
+
+	var broker *transport.RunBroker
+	if cfg.EnableRunFanOut {
+		broker = transport.NewRunBroker(transport.ReplayMode(cfg.FanOutReplay))
+	}
+
+	var limiter *transport.RunLimiter
+	if cfg.MaxConcurrentRuns > 0 {
+		limiter = transport.NewRunLimiter(cfg.MaxConcurrentRuns)
+	}
+
+	// Transport handlers; a disabled transport is left nil and not registered
+	var sseHandler *sse.Handler
+	if cfg.EnableSSE {
+		sseHandler = sse.NewHandler(cfg, adapter, stateMgr, broker, limiter)
+	}
+	var connectHandler *connectrpc.Handler
+	if cfg.EnableConnect {
+		connectHandler = connectrpc.NewHandler(cfg, adapter, stateMgr, broker, limiter)
+	}
+
+	srv := server.New(cfg, sseHandler, connectHandler, stateMgr, adapter)
+
+	// Forget idle threads
+	if cfg.ThreadTTL > 0 {
+		go cleanupThreads(cfg.ThreadTTL, stateMgr, sessionMgr)
+	}
+
+	// Readiness waits for the model to be reachable when warmup is enabled
+	if cfg.Warmup {
+		go warmup(agentFactory, cfg.WarmupTimeout, srv)
+	} else {
+		srv.SetReady(true)
+	}
+
+	// Shut down gracefully on SIGINT/SIGTERM
+	// In-flight runs get to finish; those still running near the deadline are interrupted,
+	// so their clients are told to reconnect before the connections close
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+
+		log.Println("Shutting down server...")
+		srv.SetReady(false)
+		interrupt := time.AfterFunc(shutdownTimeout-shutdownNotice, adapter.Shutdown)
+		defer interrupt.Stop()
+		if err := srv.ShutdownTimeout(shutdownTimeout); err != nil {
+			log.Printf("Error during shutdown: %v", err)
+		}
+	}()
+
+	if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Server error: %v", err)
+	}
+	// Start returns as soon as shutdown begins; wait for in-flight requests to drain
+	<-shutdownDone
+}
+
+// warmupRetryInterval is the delay between failed warmup attempts
+const warmupRetryInterval = 5 * time.Second
+
+// warmup retries the model warmup until it succeeds, then marks the server ready
+func warmup(agentFactory *agent.Factory, timeout time.Duration, srv *server.Server) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := agentFactory.Warmup(ctx)
+		cancel()
+		if err == nil {
+			log.Println("Model warmup complete")
+			srv.SetReady(true)
+			return
+		}
+		log.Printf("Model warmup failed, retrying in %v: %v", warmupRetryInterval, err)
+		time.Sleep(warmupRetryInterval)
+	}
+}
+
+// cleanupThreads periodically removes thread state, and the sessions (with their owners), unused for ttl
+func cleanupThreads(ttl time.Duration, stateMgr *transport.StateManager, sessionMgr *session.Manager) {
+	ticker := time.NewTicker(min(ttl, time.Minute))
+	defer ticker.Stop()
+	for range ticker.C {
+		if removed := stateMgr.Cleanup(ttl); removed > 0 {
+			log.Printf("Cleaned up %d idle thread entries", removed)
+		}
+		removed, err := sessionMgr.Cleanup(context.Background(), ttl)
+		if err != nil {
+			log.Printf("Error cleaning up idle sessions: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("Cleaned up %d idle sessions", removed)
+		}
+	}
+}
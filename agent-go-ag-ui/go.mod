@@ -5,9 +5,12 @@ go 1.25.5
 require (
 	connectrpc.com/connect v1.19.1
 	github.com/ag-ui-protocol/ag-ui/sdks/community/go v0.0.0-20251209183222-5f9a819f383e
+	golang.org/x/net v0.47.0
+	golang.org/x/text v0.31.0
 	google.golang.org/adk v0.2.0
 	google.golang.org/genai v1.39.0
 	google.golang.org/protobuf v1.36.11
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -32,9 +35,7 @@ require (
 	go.opentelemetry.io/otel/sdk v1.38.0 // indirect
 	go.opentelemetry.io/otel/trace v1.38.0 // indirect
 	golang.org/x/crypto v0.45.0 // indirect
-	golang.org/x/net v0.47.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
-	golang.org/x/text v0.31.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20251014184007-4626949a642f // indirect
 	google.golang.org/grpc v1.76.0 // indirect
 	rsc.io/omap v1.2.0 // indirect
@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"agent-go-ag-ui/internal/config"
+	"agent-go-ag-ui/internal/transport"
+
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/agent/llmagent"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/model/gemini"
+	"google.golang.org/adk/tool"
+	"google.golang.org/genai"
+)
+
+// Factory creates the ADK agents and models of the application
+// All models share one genai client configuration, and so one HTTP connection pool
+type Factory struct {
+	cfg          *config.Config
+	clientConfig *genai.ClientConfig
+	client       *genai.Client
+	// models lists the models of the agents created so far, checked by Warmup
+	models []string
+}
+
+// modelName is the Gemini model behind the built-in agent
+const modelName = "gemini-3-pro-preview"
+
+// NewFactory creates an agent factory and the shared genai client
+// With TranscriptPath set no client is created, since the model is never called
+func NewFactory(ctx context.Context, cfg *config.Config) (*Factory, error) {
+	f := &Factory{cfg: cfg}
+	if cfg.TranscriptPath != "" {
+		return f, nil
+	}
+
+	// The default transport honors HTTPS_PROXY/HTTP_PROXY/NO_PROXY; an explicit proxy overrides them
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ModelProxyURL != nil {
+		transport.Proxy = http.ProxyURL(cfg.ModelProxyURL)
+	}
+
+	f.clientConfig = &genai.ClientConfig{
+		APIKey: cfg.GoogleAPIKey,
+		// Shared by every client built from this config, so connections are reused
+		HTTPClient: &http.Client{Transport: transport},
+	}
+	client, err := genai.NewClient(ctx, f.clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	f.client = client
+
+	return f, nil
+}
+
+// Client returns the shared genai client, for direct model calls outside of agents
+// It is nil when replaying a transcript
+func (f *Factory) Client() *genai.Client {
+	return f.client
+}
+
+// Model creates a Gemini model backed by the shared client configuration
+func (f *Factory) Model(ctx context.Context, name string) (model.LLM, error) {
+	return gemini.NewModel(ctx, name, f.clientConfig)
+}
+
+// Warmup checks that the agents' models are reachable, so the first request doesn't pay
+// for connection setup; it fetches each model's metadata, which costs no tokens
+// It is a no-op when replaying a transcript
+func (f *Factory) Warmup(ctx context.Context) error {
+	if f.client == nil {
+		return nil
+	}
+	models := f.models
+	if len(models) == 0 {
+		models = []string{modelName}
+	}
+	for _, name := range models {
+		if err := f.checkModel(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkModel fetches a model's metadata, failing if it doesn't exist or isn't reachable
+func (f *Factory) checkModel(ctx context.Context, name string) error {
+	if _, err := f.client.Models.Get(ctx, name, nil); err != nil {
+		return fmt.Errorf("model %s is not reachable: %w", name, err)
+	}
+	return nil
+}
+
+// Registry builds the agents clients can select: those defined in AGENTS_CONFIG, or the
+// built-in agent; each configured model is checked at startup, so unknown models fail early
+// With TranscriptPath set, every agent replays the transcript instead of calling the model
+func (f *Factory) Registry(ctx context.Context) (*Registry, error) {
+	if f.cfg.AgentsConfigPath == "" {
+		defaultAgent, err := f.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		registry := NewRegistry(defaultAgent)
+		if f.cfg.TranscriptPath == "" {
+			def, _ := f.builtinDefinition()
+			registry.setTools(defaultAgent.Name(), def.toolNames())
+		}
+		return registry, nil
+	}
+
+	defs, err := LoadDefinitions(f.cfg.AgentsConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	var registry *Registry
+	for _, def := range defs {
+		a, err := f.fromDefinition(ctx, def)
+		if err != nil {
+			return nil, fmt.Errorf("agent %q: %w", def.Name, err)
+		}
+		if registry == nil {
+			registry = NewRegistry(a)
+		} else if err := registry.Register(a); err != nil {
+			return nil, err
+		}
+		if f.cfg.TranscriptPath == "" {
+			registry.setTools(def.Name, def.toolNames())
+		}
+	}
+	return registry, nil
+}
+
+// New creates and returns the built-in ADK agent, with the tools of ENABLED_TOOLS if set
+// With TranscriptPath set, the agent replays the transcript instead of calling the model
+func (f *Factory) New(ctx context.Context) (agent.Agent, error) {
+	def, err := f.builtinDefinition()
+	if err != nil {
+		return nil, err
+	}
+	if f.cfg.TranscriptPath != "" {
+		return newTranscriptAgent(transcriptAgentName, f.cfg.TranscriptPath)
+	}
+	return f.newLLMAgent(ctx, def)
+}
+
+// builtinDefinition returns the built-in agent's definition, with the tools of ENABLED_TOOLS if set
+func (f *Factory) builtinDefinition() (Definition, error) {
+	def := defaultDefinition
+	if len(f.cfg.EnabledTools) > 0 {
+		def.Tools = f.cfg.EnabledTools
+	}
+	if err := def.validate(); err != nil {
+		return Definition{}, fmt.Errorf("ENABLED_TOOLS: %w", err)
+	}
+	return def, nil
+}
+
+// fromDefinition creates a configured agent, after checking its model exists
+func (f *Factory) fromDefinition(ctx context.Context, def Definition) (agent.Agent, error) {
+	if f.cfg.TranscriptPath != "" {
+		return newTranscriptAgent(def.Name, f.cfg.TranscriptPath)
+	}
+	if !slices.Contains(f.models, def.Model) {
+		if err := f.checkModel(ctx, def.Model); err != nil {
+			return nil, err
+		}
+	}
+	return f.newLLMAgent(ctx, def)
+}
+
+// newLLMAgent creates a model-backed agent from a definition
+func (f *Factory) newLLMAgent(ctx context.Context, def Definition) (agent.Agent, error) {
+	model, err := f.Model(ctx, def.Model)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(f.models, def.Model) {
+		f.models = append(f.models, def.Model)
+	}
+
+	return llmagent.New(llmagent.Config{
+		Name:                 def.Name,
+		Model:                model,
+		Description:          def.Description,
+		InstructionProvider:  runInstruction(def.Instruction),
+		BeforeModelCallbacks: []llmagent.BeforeModelCallback{applySampling, applyStopSequences, applyResponseFormat},
+		GenerateContentConfig: &genai.GenerateContentConfig{
+			Temperature:   f.cfg.DefaultTemperature,
+			TopP:          f.cfg.DefaultTopP,
+			StopSequences: f.cfg.StopSequences,
+			// Thought summaries are surfaced to clients as THINKING events
+			ThinkingConfig: &genai.ThinkingConfig{
+				IncludeThoughts: f.cfg.EnableThinking,
+			},
+			SafetySettings: f.cfg.SafetySettings,
+		},
+		Tools: def.tools(),
+		// Tools declared by the client in the request, for that run only
+		Toolsets: []tool.Toolset{clientToolset{}},
+	})
+}
+
+// runInstruction returns an instruction provider that appends the per-run instructions
+// carried by the run context (e.g. the response language) to the base instruction
+func runInstruction(base string) llmagent.InstructionProvider {
+	return func(ctx agent.ReadonlyContext) (string, error) {
+		instruction := base
+		for _, extra := range transport.RunInstructionsFromContext(ctx) {
+			instruction += "\n\n" + extra
+		}
+		return instruction, nil
+	}
+}
+
+// applySampling applies the run's sampling overrides carried by the run context to a model request
+func applySampling(ctx agent.CallbackContext, req *model.LLMRequest) (*model.LLMResponse, error) {
+	sampling := transport.SamplingFromContext(ctx)
+	if sampling.Temperature == nil && sampling.TopP == nil {
+		return nil, nil
+	}
+	genConfig := copyRequestConfig(req)
+	if sampling.Temperature != nil {
+		genConfig.Temperature = sampling.Temperature
+	}
+	if sampling.TopP != nil {
+		genConfig.TopP = sampling.TopP
+	}
+	req.Config = genConfig
+	return nil, nil
+}
+
+// applyStopSequences replaces the agent's stop sequences with the run's, when the run context carries them
+func applyStopSequences(ctx agent.CallbackContext, req *model.LLMRequest) (*model.LLMResponse, error) {
+	sequences, ok := transport.StopSequencesFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	genConfig := copyRequestConfig(req)
+	genConfig.StopSequences = sequences
+	req.Config = genConfig
+	return nil, nil
+}
+
+// applyResponseFormat switches a model request to JSON output when the run context asks for it
+func applyResponseFormat(ctx agent.CallbackContext, req *model.LLMRequest) (*model.LLMResponse, error) {
+	format := transport.ResponseFormatFromContext(ctx)
+	if !format.JSON {
+		return nil, nil
+	}
+	genConfig := copyRequestConfig(req)
+	genConfig.ResponseMIMEType = "application/json"
+	if format.Schema != nil {
+		genConfig.ResponseJsonSchema = format.Schema
+	}
+	req.Config = genConfig
+	return nil, nil
+}
+
+// copyRequestConfig returns a copy of a model request's config, so the agent's defaults are left untouched
+func copyRequestConfig(req *model.LLMRequest) *genai.GenerateContentConfig {
+	if req.Config == nil {
+		return &genai.GenerateContentConfig{}
+	}
+	copied := *req.Config
+	return &copied
+}
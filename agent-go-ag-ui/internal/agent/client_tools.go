@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"log"
+
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/model"
+	"google.golang.org/adk/tool"
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+// clientToolset exposes the tools the client declared for the run (carried by the run context)
+// to the model, next to the agent's own tools
+type clientToolset struct{}
+
+// Name returns the name of the toolset
+func (clientToolset) Name() string {
+	return "client_tools"
+}
+
+// Tools returns the run's client tools
+func (clientToolset) Tools(ctx agent.ReadonlyContext) ([]tool.Tool, error) {
+	declared := transport.ClientToolsFromContext(ctx)
+	tools := make([]tool.Tool, 0, len(declared))
+	for _, t := range declared {
+		tools = append(tools, clientTool{t})
+	}
+	return tools, nil
+}
+
+// clientTool declares a client tool to the model; it is long-running, so a call to it
+// ends the run with the open tool call and the client sends the result in the next run
+type clientTool struct {
+	transport.ClientTool
+}
+
+// Name returns the tool's name
+func (t clientTool) Name() string {
+	return t.ClientTool.Name
+}
+
+// Description returns the tool's description
+func (t clientTool) Description() string {
+	return t.ClientTool.Description
+}
+
+// IsLongRunning reports that the result arrives later, from the client
+func (clientTool) IsLongRunning() bool {
+	return true
+}
+
+// Declaration returns the function declaration sent to the model, with the client's JSON Schema
+func (t clientTool) Declaration() *genai.FunctionDeclaration {
+	decl := &genai.FunctionDeclaration{
+		Name:        t.ClientTool.Name,
+		Description: t.ClientTool.Description,
+	}
+	if t.Parameters != nil {
+		decl.ParametersJsonSchema = t.Parameters
+	}
+	return decl
+}
+
+// Run is only reached if the run isn't paused at the call; the client executes the tool
+func (t clientTool) Run(tool.Context, any) (map[string]any, error) {
+	return map[string]any{"status": "pending", "message": "executed by the client"}, nil
+}
+
+// ProcessRequest adds the tool's declaration to the model request
+// Requests declaring a client tool named like one of the agent's tools are rejected when the
+// agent is selected; should one get here anyway, it is skipped and the agent's tool wins
+func (t clientTool) ProcessRequest(ctx tool.Context, req *model.LLMRequest) error {
+	if _, exists := req.Tools[t.ClientTool.Name]; exists {
+		log.Printf("Ignoring client tool %q: the agent has a tool with that name", t.ClientTool.Name)
+		return nil
+	}
+	if req.Tools == nil {
+		req.Tools = make(map[string]any)
+	}
+	req.Tools[t.ClientTool.Name] = t
+
+	if req.Config == nil {
+		req.Config = &genai.GenerateContentConfig{}
+	}
+	// Declarations share the request's function tool, as the ADK function tools do
+	for _, existing := range req.Config.Tools {
+		if existing != nil && existing.FunctionDeclarations != nil {
+			existing.FunctionDeclarations = append(existing.FunctionDeclarations, t.Declaration())
+			return nil
+		}
+	}
+	req.Config.Tools = append(req.Config.Tools, &genai.Tool{FunctionDeclarations: []*genai.FunctionDeclaration{t.Declaration()}})
+	return nil
+}
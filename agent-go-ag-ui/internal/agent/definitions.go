@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/adk/tool"
+	"gopkg.in/yaml.v3"
+)
+
+// Definition describes an agent built from configuration
+type Definition struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Model       string   `yaml:"model"`
+	Instruction string   `yaml:"instruction"`
+	Tools       []string `yaml:"tools"`
+	// Default serves the agent when a request selects none (at most one; the first agent otherwise)
+	Default bool `yaml:"default"`
+}
+
+// agentsFile is the layout of the AGENTS_CONFIG file
+type agentsFile struct {
+	Agents []Definition `yaml:"agents"`
+}
+
+// defaultDefinition is the built-in agent, used when no AGENTS_CONFIG is given
+var defaultDefinition = Definition{
+	Name:        "hello_time_agent",
+	Description: "Tells the current time in a specified city.",
+	Model:       modelName,
+	Instruction: "You are a helpful assistant that tells the current time in a city.",
+	Tools:       []string{"google_search"},
+	Default:     true,
+}
+
+// LoadDefinitions reads agent definitions from a YAML (or JSON) file and validates them
+// The default agent is listed first in the result
+func LoadDefinitions(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agents config: %w", err)
+	}
+
+	var file agentsFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse agents config %s: %w", path, err)
+	}
+	if len(file.Agents) == 0 {
+		return nil, fmt.Errorf("agents config %s defines no agents", path)
+	}
+
+	names := make(map[string]bool)
+	defaultIndex := -1
+	for i, def := range file.Agents {
+		if def.Name == "" {
+			return nil, fmt.Errorf("agents config %s: agent %d: name is required", path, i)
+		}
+		if err := def.validate(); err != nil {
+			return nil, fmt.Errorf("agents config %s: agent %q: %w", path, def.Name, err)
+		}
+		if names[def.Name] {
+			return nil, fmt.Errorf("agents config %s: agent %q is defined twice", path, def.Name)
+		}
+		names[def.Name] = true
+		if def.Default {
+			if defaultIndex >= 0 {
+				return nil, fmt.Errorf("agents config %s: both %q and %q are marked default", path, file.Agents[defaultIndex].Name, def.Name)
+			}
+			defaultIndex = i
+		}
+	}
+
+	if defaultIndex <= 0 {
+		return file.Agents, nil
+	}
+	defs := []Definition{file.Agents[defaultIndex]}
+	for i, def := range file.Agents {
+		if i != defaultIndex {
+			defs = append(defs, def)
+		}
+	}
+	return defs, nil
+}
+
+// validate checks a named definition has a model and instruction and references only known tools
+func (d Definition) validate() error {
+	if d.Model == "" {
+		return errors.New("model is required")
+	}
+	if d.Instruction == "" {
+		return errors.New("instruction is required")
+	}
+	for _, name := range d.Tools {
+		if _, ok := lookupTool(name); !ok {
+			return fmt.Errorf("unknown tool %q (available: %s)", name, strings.Join(toolNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// tools builds the tools a definition references
+func (d Definition) tools() []tool.Tool {
+	tools := make([]tool.Tool, 0, len(d.Tools))
+	for _, name := range d.Tools {
+		t, _ := lookupTool(name)
+		tools = append(tools, t)
+	}
+	return tools
+}
+
+// toolNames returns the names the agent's tools are declared to the model with
+func (d Definition) toolNames() []string {
+	names := make([]string, 0, len(d.Tools))
+	for _, t := range d.tools() {
+		names = append(names, t.Name())
+	}
+	return names
+}
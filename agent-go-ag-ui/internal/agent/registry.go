@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/adk/agent"
+)
+
+// ErrUnknownAgent is returned when a client selects an agent that isn't registered
+var ErrUnknownAgent = errors.New("unknown agent")
+
+// Registry holds the agents clients can select by name
+type Registry struct {
+	agents map[string]agent.Agent
+	// tools lists the names of each agent's own tools, so client tools can't shadow them
+	tools       map[string][]string
+	defaultName string
+}
+
+// NewRegistry creates a registry serving defaultAgent when no agent is selected
+func NewRegistry(defaultAgent agent.Agent) *Registry {
+	return &Registry{
+		agents:      map[string]agent.Agent{defaultAgent.Name(): defaultAgent},
+		tools:       make(map[string][]string),
+		defaultName: defaultAgent.Name(),
+	}
+}
+
+// Register adds an agent under its name
+func (r *Registry) Register(a agent.Agent) error {
+	if _, exists := r.agents[a.Name()]; exists {
+		return fmt.Errorf("agent %q is already registered", a.Name())
+	}
+	r.agents[a.Name()] = a
+	return nil
+}
+
+// Get returns the agent with the given name, or the default agent for ""
+func (r *Registry) Get(name string) (agent.Agent, error) {
+	if name == "" {
+		name = r.defaultName
+	}
+	a, ok := r.agents[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %s)", ErrUnknownAgent, name, strings.Join(r.Names(), ", "))
+	}
+	return a, nil
+}
+
+// setTools records the names of a registered agent's own tools
+func (r *Registry) setTools(name string, tools []string) {
+	r.tools[name] = tools
+}
+
+// ToolNames returns the names of an agent's own tools, as declared to the model
+func (r *Registry) ToolNames(name string) []string {
+	return r.tools[name]
+}
+
+// Names returns the registered agent names, sorted
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.agents))
+	for name := range r.agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/geminitool"
+)
+
+// toolsMu guards knownTools, which RegisterTool may extend
+var toolsMu sync.RWMutex
+
+// knownTools are the tools agent definitions can reference by name
+var knownTools = map[string]func() tool.Tool{
+	"google_search": func() tool.Tool { return geminitool.GoogleSearch{} },
+}
+
+// RegisterTool makes a custom ADK tool available to agents under name, so embedders can add
+// tools without editing this package; agents use it once it is listed in their tools
+// (AGENTS_CONFIG) or in ENABLED_TOOLS for the built-in agent
+// Call it from an init function or before the agents are built; like database/sql.Register,
+// it panics if the name is empty or already registered, or the tool is nil
+func RegisterTool(name string, t tool.Tool) {
+	toolsMu.Lock()
+	defer toolsMu.Unlock()
+
+	if name == "" {
+		panic("agent: RegisterTool with an empty name")
+	}
+	if t == nil {
+		panic(fmt.Sprintf("agent: RegisterTool %q with a nil tool", name))
+	}
+	if _, exists := knownTools[name]; exists {
+		panic(fmt.Sprintf("agent: RegisterTool called twice for tool %q", name))
+	}
+	knownTools[name] = func() tool.Tool { return t }
+}
+
+// lookupTool builds the tool registered under name
+func lookupTool(name string) (tool.Tool, bool) {
+	toolsMu.RLock()
+	defer toolsMu.RUnlock()
+
+	newTool, ok := knownTools[name]
+	if !ok {
+		return nil, false
+	}
+	return newTool(), true
+}
+
+// toolNames returns the known tool names, sorted
+func toolNames() []string {
+	toolsMu.RLock()
+	defer toolsMu.RUnlock()
+
+	names := make([]string, 0, len(knownTools))
+	for name := range knownTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"os"
+	"time"
+
+	"google.golang.org/adk/agent"
+	"google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// transcriptAgentName is the name of the replaying agent when no agents are configured
+const transcriptAgentName = "transcript_agent"
+
+// TranscriptStep is one scripted step of a replayed run
+// Exactly one of Text, ToolCall, ToolResult or DelayMs is set
+type TranscriptStep struct {
+	// Text is a chunk of assistant text
+	Text string `json:"text,omitempty"`
+	// ToolCall is a function call made by the model
+	ToolCall *genai.FunctionCall `json:"toolCall,omitempty"`
+	// ToolResult is the result of a function call
+	ToolResult *genai.FunctionResponse `json:"toolResult,omitempty"`
+	// DelayMs pauses the replay, to simulate model and tool latency
+	DelayMs int `json:"delayMs,omitempty"`
+}
+
+// loadTranscript reads a JSON array of transcript steps
+func loadTranscript(path string) ([]TranscriptStep, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	var steps []TranscriptStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("failed to parse transcript %s: %w", path, err)
+	}
+	for i, step := range steps {
+		if step.Text == "" && step.ToolCall == nil && step.ToolResult == nil && step.DelayMs <= 0 {
+			return nil, fmt.Errorf("transcript %s: step %d is empty", path, i)
+		}
+	}
+	return steps, nil
+}
+
+// newTranscriptAgent creates an agent that replays a recorded transcript instead of calling the model
+// Every run emits the same events through the normal ADK path, for reproducible load tests
+func newTranscriptAgent(name, path string) (agent.Agent, error) {
+	steps, err := loadTranscript(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return agent.New(agent.Config{
+		Name:        name,
+		Description: "Replays a recorded transcript for load testing.",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*session.Event, error] {
+			return func(yield func(*session.Event, error) bool) {
+				for i, step := range steps {
+					if step.DelayMs > 0 {
+						select {
+						case <-ctx.Done():
+							yield(nil, ctx.Err())
+							return
+						case <-time.After(time.Duration(step.DelayMs) * time.Millisecond):
+						}
+					}
+
+					part := transcriptPart(step)
+					if part == nil {
+						continue
+					}
+
+					event := session.NewEvent(ctx.InvocationID())
+					event.Author = name
+					event.Content = genai.NewContentFromParts([]*genai.Part{part}, genai.RoleModel)
+					// Only the last step completes the response
+					event.Partial = i < len(steps)-1
+					if !yield(event, nil) {
+						return
+					}
+				}
+			}
+		},
+	})
+}
+
+// transcriptPart converts a step to a content part, or nil for delay-only steps
+func transcriptPart(step TranscriptStep) *genai.Part {
+	switch {
+	case step.ToolCall != nil:
+		return &genai.Part{FunctionCall: step.ToolCall}
+	case step.ToolResult != nil:
+		return &genai.Part{FunctionResponse: step.ToolResult}
+	case step.Text != "":
+		return genai.NewPartFromText(step.Text)
+	default:
+		return nil
+	}
+}
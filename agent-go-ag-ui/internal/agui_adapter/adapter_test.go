@@ -0,0 +1,172 @@
+package agui_adapter
+
+import (
+	"context"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/adk/agent"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/config"
+	"agent-go-ag-ui/internal/session"
+	"agent-go-ag-ui/internal/transport"
+)
+
+// testConfig returns the configuration Load produces with no environment set
+func testConfig() *config.Config {
+	return &config.Config{
+		AppName:               "test-app",
+		AssistantRole:         "assistant",
+		AgentTimeout:          10 * time.Second,
+		ModelRetryAttempts:    1,
+		TextChunking:          "token",
+		EmptyResponse:         "fallback",
+		ToolResultMessageMode: "separate",
+		ImageMaxBytes:         10 << 20,
+		ModerationErrorCode:   "moderation_rejected",
+		SessionUserIsolation:  true,
+	}
+}
+
+// staticAgents resolves every agent name to the same agent
+type staticAgents struct {
+	agent agent.Agent
+}
+
+func (s staticAgents) Get(string) (agent.Agent, error) {
+	return s.agent, nil
+}
+
+// scriptedAgent yields one model event per step, each carrying the step's parts
+// A step without parts blocks until the run is cancelled
+func scriptedAgent(t *testing.T, steps ...[]*genai.Part) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "scripted_agent",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(yield func(*adksession.Event, error) bool) {
+				for i, parts := range steps {
+					if len(parts) == 0 {
+						<-ctx.Done()
+						yield(nil, ctx.Err())
+						return
+					}
+					event := adksession.NewEvent(ctx.InvocationID())
+					event.Author = "scripted_agent"
+					event.Content = genai.NewContentFromParts(parts, genai.RoleModel)
+					event.Partial = i < len(steps)-1
+					if !yield(event, nil) {
+						return
+					}
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+// newTestAdapter creates an adapter running a, with the default configuration adjusted by configure
+func newTestAdapter(a agent.Agent, configure func(*config.Config)) *AGUIAdapter {
+	cfg := testConfig()
+	if configure != nil {
+		configure(cfg)
+	}
+	sessionMgr := session.NewManager(session.RetryPolicy{}, cfg.SessionUserIsolation)
+	return NewAGUIAdapter(cfg, staticAgents{a}, sessionMgr, nil, nil, nil, nil)
+}
+
+// collectingSender records every event it is sent
+type collectingSender struct {
+	events []events.Event
+}
+
+func (c *collectingSender) SendEvent(event events.Event) error {
+	c.events = append(c.events, event)
+	return nil
+}
+
+func (c *collectingSender) SendRunError(runID string, err error) error {
+	return c.SendEvent(events.NewRunErrorEvent(err.Error(), events.WithRunID(runID)))
+}
+
+// userInput is a run input with a single user message
+func userInput(threadID, text string) *RunAgentInput {
+	return &RunAgentInput{
+		ThreadID: threadID,
+		RunID:    "run-1",
+		Messages: []map[string]interface{}{
+			{"id": "msg-1", "role": "user", "content": text},
+		},
+	}
+}
+
+// runProtocol runs input through the adapter as principal and returns the events sent
+func runProtocol(t *testing.T, a *AGUIAdapter, principal string, input *RunAgentInput) []events.Event {
+	t.Helper()
+	ctx := context.Background()
+	if principal != "" {
+		ctx = transport.WithPrincipal(ctx, principal)
+	}
+	sender := &collectingSender{}
+	if err := a.RunAgentProtocol(ctx, input, transport.NewStateManager(0), sender); err != nil {
+		t.Fatalf("RunAgentProtocol: %v", err)
+	}
+	return sender.events
+}
+
+// eventTypes lists the types of events, for comparing event sequences
+func eventTypes(evts []events.Event) []events.EventType {
+	types := make([]events.EventType, 0, len(evts))
+	for _, event := range evts {
+		types = append(types, event.Type())
+	}
+	return types
+}
+
+// withoutType drops the events of the given types, e.g. CUSTOM events a test doesn't look at
+func withoutType(evts []events.Event, drop ...events.EventType) []events.Event {
+	kept := make([]events.Event, 0, len(evts))
+	for _, event := range evts {
+		skip := false
+		for _, eventType := range drop {
+			if event.Type() == eventType {
+				skip = true
+			}
+		}
+		if !skip {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// runError returns the run's RUN_ERROR event, or nil if it didn't fail
+func runError(evts []events.Event) *events.RunErrorEvent {
+	for _, event := range evts {
+		if e, ok := event.(*events.RunErrorEvent); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// assistantText joins the TEXT_MESSAGE_CONTENT deltas of a run
+func assistantText(evts []events.Event) string {
+	text := ""
+	for _, event := range evts {
+		switch e := event.(type) {
+		case *events.TextMessageContentEvent:
+			text += e.Delta
+		case *SequencedTextMessageContentEvent:
+			text += e.Delta
+		}
+	}
+	return text
+}
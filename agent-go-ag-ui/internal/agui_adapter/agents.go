@@ -0,0 +1,54 @@
+package agui_adapter
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/adk/agent"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+// ForwardedPropAgent selects the agent for a single run by name
+const ForwardedPropAgent = "agent"
+
+// AgentResolver looks up the agent a run is executed with
+type AgentResolver interface {
+	// Get returns the agent with the given name, or the default agent for ""
+	Get(name string) (agent.Agent, error)
+}
+
+// AgentToolLister is implemented by resolvers that know the agents' own tools (e.g. agent.Registry)
+// With it, client tools named like one of the agent's tools are rejected when the agent is selected
+type AgentToolLister interface {
+	// ToolNames returns the names of an agent's own tools
+	ToolNames(agentName string) []string
+}
+
+// SelectAgent resolves the agent for a request, selected by the request path
+// (e.g. /sse/{agentName}) or by forwardedProps.agent; without either, the default agent runs
+// Handlers call it before the run starts so an unknown agent, or a client tool that would shadow
+// one of the agent's tools, is rejected up front
+func (a *AGUIAdapter) SelectAgent(ctx context.Context, input *RunAgentInput) (agent.Agent, error) {
+	name := transport.AgentNameFromContext(ctx)
+	if bodyName, _ := input.ForwardedProps[ForwardedPropAgent].(string); bodyName != "" {
+		if name != "" && bodyName != name {
+			return nil, fmt.Errorf("forwardedProps '%s' %q conflicts with agent %q in the path", ForwardedPropAgent, bodyName, name)
+		}
+		name = bodyName
+	}
+	selected, err := a.agents.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if lister, ok := a.agents.(AgentToolLister); ok {
+		for _, toolName := range lister.ToolNames(selected.Name()) {
+			for _, clientTool := range clientTools(input.Tools) {
+				if clientTool.Name == toolName {
+					return nil, fmt.Errorf("tool %q conflicts with a tool of agent %q", toolName, selected.Name())
+				}
+			}
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,49 @@
+package agui_adapter
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// toolAgents resolves every agent name to the same agent and reports its own tools
+type toolAgents struct {
+	staticAgents
+	tools []string
+}
+
+func (t toolAgents) ToolNames(string) []string {
+	return t.tools
+}
+
+func TestSelectAgentRejectsShadowingClientTools(t *testing.T) {
+	tests := []struct {
+		name    string
+		tools   []interface{}
+		wantErr bool
+	}{
+		{"no client tools", nil, false},
+		{"distinct client tool", []interface{}{map[string]interface{}{"name": "confirm"}}, false},
+		{"client tool named like an agent tool", []interface{}{
+			map[string]interface{}{"name": "confirm"},
+			map[string]interface{}{"name": "google_search"},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agents := toolAgents{
+				staticAgents: staticAgents{scriptedAgent(t, []*genai.Part{genai.NewPartFromText("hi")})},
+				tools:        []string{"google_search"},
+			}
+			a := NewAGUIAdapter(testConfig(), agents, nil, nil, nil, nil, nil)
+
+			input := userInput("thread-1", "hello")
+			input.Tools = tt.tools
+			_, err := a.SelectAgent(context.Background(), input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SelectAgent error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,8 +3,12 @@ package agui_adapter
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
@@ -13,46 +17,196 @@ import (
 	adksession "google.golang.org/adk/session"
 	"google.golang.org/genai"
 
+	"agent-go-ag-ui/internal/config"
 	"agent-go-ag-ui/internal/session"
 	"agent-go-ag-ui/internal/transport"
 )
 
 // AGUIAdapter is the SINGLE source of truth for ADK → AG-UI event conversion
 type AGUIAdapter struct {
-	agent      agent.Agent
+	agents     AgentResolver
 	sessionMgr *session.Manager
 	appName    string
 	timeout    time.Duration
+	// assistantRole is the default role emitted on TEXT_MESSAGE_START
+	assistantRole string
+	// greeting is streamed as an assistant message on empty-messages requests (empty = disabled)
+	greeting string
+	// retryAttempts is the number of attempts for model calls that fail before producing any content
+	retryAttempts int
+	// retryBackoff is the delay before the first model retry, doubled on each further retry
+	retryBackoff time.Duration
+	// locales picks the response language for each run (nil = no language instruction)
+	locales *localeResolver
+	// logEvents logs every emitted event; logEventBodies keeps message text and tool data in those logs
+	logEvents      bool
+	logEventBodies bool
+	// finalStateSnapshot merges tool state changes into the thread state and sends a
+	// STATE_SNAPSHOT before RUN_FINISHED when the thread state changed during the run
+	finalStateSnapshot bool
+	// streamStateDeltas sends a STATE_DELTA whenever the agent's tools change the thread state mid-run
+	streamStateDeltas bool
+	// headersToProps copies the forwarded request headers into forwardedProps
+	headersToProps bool
+	// chunkSentences releases streamed text at sentence boundaries
+	chunkSentences bool
+	// emptyResponseError fails runs whose model stream ended without any event, instead of
+	// answering with the fallback text
+	emptyResponseError bool
+	// images normalizes image content into inline data
+	images *imageLoader
+	// maxToolCalls stops runs that start more tool calls than this (0 = unlimited)
+	maxToolCalls int
+	// preprocessor rewrites requests before the agent runs
+	preprocessor RequestMiddleware
+	// postProcessor, when set, buffers the full assistant text and rewrites it before sending
+	postProcessor PostProcessor
+	// emitTyping shows a typing indicator until the model's first output
+	emitTyping bool
+	// consumeAfterFinalResponse reads the agent's events until its stream ends, instead of
+	// stopping at the first final response
+	consumeAfterFinalResponse bool
+	// allowContinueRuns runs the agent on the session history when there is no new user turn,
+	// instead of failing the run
+	allowContinueRuns bool
+	// planSegments streams text preceding a tool call in the same model response as a plan message
+	planSegments bool
+	// structuredToolEvents adds tool arguments and results as objects to their events
+	structuredToolEvents bool
+	// toolResultsInAssistantMessage makes TOOL_CALL_RESULT reference the assistant message that
+	// made the call instead of a separate tool message
+	toolResultsInAssistantMessage bool
+	// idempotency replays completed runs for retried requests with the same Idempotency-Key (nil = disabled)
+	idempotency *idempotencyCache
+	// shutdown is closed by Shutdown, interrupting the runs in progress
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	// webhooks delivers run results to callback URLs (nil = callbackUrl is rejected)
+	webhooks *webhookNotifier
+	// authorizer decides whether the run's user may use the agent and thread
+	authorizer Authorizer
+	// moderator checks the user input before the model is called
+	moderator Moderator
+	// moderationErrorCode is the RUN_ERROR code of input rejected by the moderator
+	moderationErrorCode string
 }
 
+// CustomEventRequestID follows RUN_STARTED with the request ID, to correlate the run with gateway logs
+const CustomEventRequestID = "request_id"
+
+// defaultResponseText is sent when a run ends without any assistant text
+// It is emitted only here, so no transport ever sends a second fallback
+const defaultResponseText = "I received your message, but couldn't generate a response."
+
+// errToolCallLimit stops a run that exceeded the configured tool call limit
+var errToolCallLimit = errors.New("tool call limit exceeded")
+
+// errEmptyModelResponse fails a run whose model stream ended without a single event
+var errEmptyModelResponse = errors.New("empty model response")
+
 // NewAGUIAdapter creates a new AG-UI adapter
-func NewAGUIAdapter(agent agent.Agent, sessionMgr *session.Manager, appName string) *AGUIAdapter {
+// A nil preprocessor leaves requests unchanged; a nil postProcessor streams text as it is generated;
+// a nil authorizer allows every run; a nil moderator lets all input through
+func NewAGUIAdapter(cfg *config.Config, agents AgentResolver, sessionMgr *session.Manager, preprocessor RequestMiddleware, postProcessor PostProcessor, authorizer Authorizer, moderator Moderator) *AGUIAdapter {
+	if preprocessor == nil {
+		preprocessor = NoopRequestMiddleware{}
+	}
+	if authorizer == nil {
+		authorizer = AllowAllAuthorizer{}
+	}
+	if moderator == nil {
+		moderator = NoopModerator{}
+	}
 	return &AGUIAdapter{
-		agent:      agent,
-		sessionMgr: sessionMgr,
-		appName:    appName,
-		timeout:    60 * time.Second,
+		agents:         agents,
+		sessionMgr:     sessionMgr,
+		appName:        cfg.AppName,
+		timeout:        cfg.AgentTimeout,
+		assistantRole:  cfg.AssistantRole,
+		greeting:       cfg.InitialGreeting,
+		maxToolCalls:   cfg.MaxToolCalls,
+		images:         newImageLoader(cfg.ImageMaxBytes, cfg.ImageFetchTimeout),
+		chunkSentences: cfg.TextChunking == "sentence",
+		preprocessor:   preprocessor,
+		postProcessor:  postProcessor,
+		retryAttempts:  cfg.ModelRetryAttempts,
+		retryBackoff:   cfg.ModelRetryBackoff,
+		locales:        newLocaleResolver(cfg.SupportedLocales, cfg.DefaultLocale),
+		headersToProps: cfg.ForwardHeadersToProps,
+		logEvents:      cfg.LogEvents,
+		logEventBodies: cfg.LogEventBodies,
+		webhooks:       newWebhookNotifier(cfg.WebhookTimeout, cfg.WebhookAttempts),
+		idempotency:    newIdempotencyCache(cfg.IdempotencyTTL),
+		shutdown:       make(chan struct{}),
+		authorizer:     authorizer,
+		moderator:      moderator,
+
+		finalStateSnapshot:   cfg.FinalStateSnapshot,
+		streamStateDeltas:    cfg.StreamStateDeltas,
+		structuredToolEvents: cfg.StructuredToolEvents,
+		emitTyping:           cfg.EmitTyping,
+		planSegments:         cfg.PlanSegments,
+		moderationErrorCode:  cfg.ModerationErrorCode,
+
+		consumeAfterFinalResponse: cfg.ConsumeAfterFinalResponse,
+		allowContinueRuns:         cfg.AllowContinueRuns,
+		emptyResponseError:        cfg.EmptyResponse == "error",
+
+		toolResultsInAssistantMessage: cfg.ToolResultMessageMode == "assistant",
 	}
 }
 
 // RunAgent executes the agent and streams AG-UI events
 // This is the SINGLE source of truth for ADK → AG-UI conversion
+// The returned result describes how the run ended once the channel is closed
+// threadID is the internal thread key (see transport.ThreadKey), never echoed to the client;
+// userID is the principal the run executes as, owning its session; the caller has already
+// authorized it (see authorizeRun)
+// state is the thread state the client holds at RUN_STARTED; state deltas are computed against it
 func (a *AGUIAdapter) RunAgent(
 	ctx context.Context,
 	input *RunAgentInput,
 	threadID, runID, messageID, userID string,
-) (<-chan events.Event, error) {
+	state map[string]interface{},
+) (<-chan events.Event, *RunResult, error) {
 	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	// System and developer messages extend the agent's instruction for this run
+	for _, instruction := range systemInstructions(input.Messages) {
+		ctx = transport.WithRunInstruction(ctx, instruction)
+	}
+	if a.locales != nil {
+		locale := a.locales.resolve(input.ForwardedProps, transport.AcceptLanguageFromContext(ctx))
+		ctx = transport.WithRunInstruction(ctx, localeInstruction(locale))
+	}
+	if sampling := samplingFromProps(input.ForwardedProps); sampling != (transport.Sampling{}) {
+		ctx = transport.WithSampling(ctx, sampling)
+	}
+	if sequences := stopSequencesFromProps(input.ForwardedProps); sequences != nil {
+		ctx = transport.WithStopSequences(ctx, sequences)
+	}
+	if len(input.Tools) > 0 {
+		ctx = transport.WithClientTools(ctx, clientTools(input.Tools))
+	}
+	responseFormat := responseFormatFromProps(input.ForwardedProps)
+	if responseFormat.JSON {
+		ctx = transport.WithResponseFormat(ctx, responseFormat)
+	}
 	eventChan := make(chan events.Event, 100)
+	result := &RunResult{FinishReason: FinishReasonStop}
 
 	go func() {
 		defer cancel()
 		defer close(eventChan)
 
-		// Create runner
+		// Create runner for the selected agent
+		runAgent, err := a.SelectAgent(ctx, input)
+		if err != nil {
+			eventChan <- events.NewRunErrorEvent(err.Error(), events.WithRunID(runID))
+			return
+		}
 		r, err := runner.New(runner.Config{
 			AppName:        a.appName,
-			Agent:          a.agent,
+			Agent:          runAgent,
 			SessionService: a.sessionMgr.Service(),
 		})
 		if err != nil {
@@ -62,29 +216,28 @@ func (a *AGUIAdapter) RunAgent(
 
 		// Get or create session
 		sess, err := a.sessionMgr.GetOrCreate(ctx, a.appName, userID, threadID)
+		if errors.Is(err, session.ErrForbidden) {
+			eventChan <- events.NewRunErrorEvent("forbidden", events.WithRunID(runID), events.WithErrorCode("forbidden"))
+			return
+		}
 		if err != nil {
 			eventChan <- events.NewRunErrorEvent(fmt.Sprintf("failed to get session: %v", err), events.WithRunID(runID))
 			return
 		}
 
-		// Find last user message
-		var lastUserContent *genai.Content
-		for i := len(input.Messages) - 1; i >= 0; i-- {
-			msg := input.Messages[i]
-			role, ok := msg["role"].(string)
-			if !ok || role != "user" {
-				continue
-			}
-			content, ok := msg["content"].(string)
-			if ok && content != "" {
-				lastUserContent = genai.NewContentFromText(content, genai.RoleUser)
-				break
-			}
+		// Build the new turn: tool results or last user message
+		lastUserContent, err := buildRunContent(ctx, input.Messages, sess.Events(), a.images)
+		if err != nil {
+			eventChan <- events.NewRunErrorEvent(fmt.Sprintf("invalid message content: %v", err), events.WithRunID(runID))
+			return
 		}
-
+		// Without a new turn, a continue run picks up from the session history (nil content adds nothing to it)
 		if lastUserContent == nil {
-			eventChan <- events.NewRunErrorEvent("no valid user message found", events.WithRunID(runID))
-			return
+			if !a.allowContinueRuns || sess.Events().Len() == 0 {
+				eventChan <- events.NewRunErrorEvent("no valid user message found", events.WithRunID(runID))
+				return
+			}
+			log.Printf("[%s] No new user turn, continuing thread %s from its session history", transport.RequestIDFromContext(ctx), threadID)
 		}
 
 		// Run agent
@@ -92,74 +245,233 @@ func (a *AGUIAdapter) RunAgent(
 		adkEvents := r.Run(ctx, userID, sess.ID(), lastUserContent, runConfig)
 
 		// Convert ADK events to AG-UI events
-		var responseBuilder strings.Builder
-		toolCallMap := make(map[string]string)
-		startedToolCalls := make(map[string]bool)
+		role := input.AssistantRole(a.assistantRole)
+		tr := newRunTranslation(messageID, role, eventChan, a.postProcessor != nil, a.chunkSentences)
+		if a.streamStateDeltas {
+			tr.state = state
+		}
+		if a.emitTyping {
+			tr.startTyping()
+		}
 
-		for adkEvent := range adkEvents {
-			if adkEvent == nil {
-				continue
-			}
+		// fail closes everything the client has open before reporting the error,
+		// so no tool call or message is left dangling on the frontend
+		// Returning cancels the run context, stopping the runner
+		fail := func(message string) {
+			tr.closeToolCalls()
+			tr.finish()
+			eventChan <- events.NewRunErrorEvent(message, events.WithRunID(runID))
+		}
 
-			// Translate ADK event to AG-UI events
-			a.translateADKEvent(adkEvent, messageID, eventChan, &responseBuilder, toolCallMap, startedToolCalls)
+		// A model call that fails before producing anything is retried from the session history,
+		// which already holds the new turn, so the retry passes no new message
+		backoff := a.retryBackoff
+		received := false
+		// paused is set when the run stops at a long-running call that the client answers
+		paused := false
+	attempts:
+		for attempt := 1; ; attempt++ {
+			retry := false
+			for adkEvent, err := range adkEvents {
+				if err != nil {
+					// Timeout and cancellation end the run with what was produced so far
+					if reason, ok := finishReasonFromContext(ctx); ok {
+						result.FinishReason = reason
+						break attempts
+					}
+					if !received && attempt < a.retryAttempts {
+						log.Printf("Model call failed (attempt %d/%d), retrying in %v: %v", attempt, a.retryAttempts, backoff, err)
+						retry = true
+						break
+					}
+					fail(fmt.Sprintf("agent execution failed: %v", err))
+					return
+				}
+				if adkEvent == nil {
+					continue
+				}
+				received = true
+				collectStateDelta(result, adkEvent.Actions.StateDelta)
+				if adkEvent.FinishReason != "" {
+					result.FinishReason = finishReasonFromModel(adkEvent.FinishReason)
+				}
 
-			if adkEvent.IsFinalResponse() {
+				// Translate ADK event to AG-UI events
+				if err := a.translateADKEvent(adkEvent, tr); err != nil {
+					fail(err.Error())
+					return
+				}
+				// Tool state changes arrive with the tool's result, so the delta follows TOOL_CALL_RESULT
+				if a.streamStateDeltas && len(adkEvent.Actions.StateDelta) > 0 {
+					tr.emitStateDelta(result.StateDelta)
+				}
+
+				// Some agent flows send a final response and continue (e.g. after a tool);
+				// for those, the end of the stream marks the end of the run
+				if adkEvent.IsFinalResponse() && !a.consumeAfterFinalResponse {
+					break
+				}
+				// A long-running call (e.g. a client tool) always pauses the run until the client
+				// sends its result in a follow-up run
+				if len(adkEvent.LongRunningToolIDs) > 0 {
+					paused = true
+					break
+				}
+			}
+			if !retry {
 				break
 			}
+
+			// Let the client show a retry indicator instead of a silent stall
+			eventChan <- retryingEvent(attempt+1, a.retryAttempts, backoff)
+			select {
+			case <-ctx.Done():
+				result.FinishReason, _ = finishReasonFromContext(ctx)
+				break attempts
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			adkEvents = r.Run(ctx, userID, sess.ID(), nil, runConfig)
+		}
+
+		// Tools get the run context, so they are cancelled along with the run;
+		// calls that never returned a result are ended instead of left open
+		if result.FinishReason == FinishReasonTimeout || result.FinishReason == FinishReasonCancelled {
+			tr.flushText()
+			tr.cancelToolCalls(result.FinishReason)
+		}
+		// The client runs its tool once the call is complete, so the call is ended here
+		if paused {
+			tr.closeToolCalls()
 		}
 
-		// Default message if no content
-		if responseBuilder.Len() == 0 {
-			defaultMsg := "I received your message, but couldn't generate a response."
-			eventChan <- events.NewTextMessageContentEvent(messageID, defaultMsg)
+		// Default message if no content (a timed out or cancelled run just ends)
+		// A run whose tool calls all completed already answered with their results,
+		// and one paused at a client tool call answers once the client sends the result
+		// JSON mode has no fallback text: an empty response fails the JSON check instead
+		generated := tr.responseBuilder.Len() > 0
+		toolsOnly := (tr.toolResultsEmitted && len(tr.startedToolCalls) == 0) || paused
+		ended := result.FinishReason == FinishReasonTimeout || result.FinishReason == FinishReasonCancelled
+		// A stream that closed cleanly without any event likely hides a provider failure;
+		// one that only produced tool calls did receive events and is valid
+		if !received && !ended && a.emptyResponseError {
+			fail(errEmptyModelResponse.Error())
+			return
+		}
+		if !generated && !toolsOnly && !ended && !responseFormat.JSON {
+			tr.emitText(defaultResponseText)
 		}
+
+		// Buffered mode: the complete text is post-processed before anything is sent
+		sent := tr.responseBuilder.String()
+		if a.postProcessor != nil {
+			text, err := a.postProcessor.Process(ctx, sent)
+			if err != nil {
+				fail(fmt.Sprintf("response post-processing failed: %v", err))
+				return
+			}
+			tr.release(text)
+			sent = text
+		}
+
+		// JSON mode: the complete response must parse, checked once the model is done
+		if responseFormat.JSON && !ended && !(toolsOnly && !generated) && !isJSONResponse(sent) {
+			fail(errInvalidJSONResponse.Error())
+			return
+		}
+
+		tr.finish()
 	}()
 
-	return eventChan, nil
+	return eventChan, result, nil
 }
 
 // translateADKEvent converts ADK events to AG-UI events
 // This is the core conversion logic, shared by all transports
-func (a *AGUIAdapter) translateADKEvent(
-	adkEvent *adksession.Event,
-	messageID string,
-	eventChan chan<- events.Event,
-	responseBuilder *strings.Builder,
-	toolCallMap map[string]string,
-	startedToolCalls map[string]bool,
-) {
+// An error means the run must stop
+func (a *AGUIAdapter) translateADKEvent(adkEvent *adksession.Event, tr *runTranslation) error {
 	if adkEvent == nil {
-		return
+		return nil
 	}
 
-	if adkEvent.Content == nil {
-		return
+	if adkEvent.Content != nil {
+		if err := a.translateParts(adkEvent.Content.Parts, tr); err != nil {
+			return err
+		}
+	}
+
+	// The built-in GoogleSearch runs inside the model call, so its search is only
+	// known to be complete, with the queries it ran, once the response arrives
+	if queries := searchQueries(adkEvent.GroundingMetadata); len(queries) > 0 {
+		tr.eventChan <- events.NewCustomEvent(CustomEventSearchComplete, events.WithValue(map[string]interface{}{
+			"queries": queries,
+		}))
 	}
 
-	for _, part := range adkEvent.Content.Parts {
+	// Grounding sources (e.g. from GoogleSearch) follow the text they support
+	if citations := extractCitations(adkEvent.GroundingMetadata); len(citations) > 0 {
+		tr.eventChan <- events.NewCustomEvent(CustomEventCitations, events.WithValue(map[string]interface{}{
+			"citations": citations,
+		}))
+	}
+
+	return nil
+}
+
+// translateParts converts the content parts of an ADK event to AG-UI events
+func (a *AGUIAdapter) translateParts(parts []*genai.Part, tr *runTranslation) error {
+	parts = mergeTextParts(parts)
+	// With plan segments, text before the response's last tool call is the model's plan
+	planEnd := -1
+	if a.planSegments {
+		planEnd = lastFunctionCall(parts)
+	}
+	for i, part := range parts {
+		// Thought summary (only returned when thinking is enabled)
+		if part.Thought && part.Text != "" {
+			tr.emitThought(part.Text)
+			continue
+		}
+
 		// Text content
 		if part.Text != "" {
-			responseBuilder.WriteString(part.Text)
-			eventChan <- events.NewTextMessageContentEvent(messageID, part.Text)
+			if i < planEnd {
+				tr.emitPlan(part.Text)
+			} else {
+				tr.emitText(part.Text)
+			}
 		}
 
 		// Function call (tool call start)
 		if part.FunctionCall != nil {
-			fc := part.FunctionCall
-			agUIToolCallID := fc.ID
-			if agUIToolCallID == "" {
-				agUIToolCallID = events.GenerateToolCallID()
+			// Guard against runaway tool-calling loops
+			tr.toolCalls++
+			if a.maxToolCalls > 0 && tr.toolCalls > a.maxToolCalls {
+				return errToolCallLimit
 			}
-			toolCallMap[fc.ID] = agUIToolCallID
 
-			eventChan <- events.NewToolCallStartEvent(agUIToolCallID, fc.Name)
-			startedToolCalls[agUIToolCallID] = true
+			fc := part.FunctionCall
+			agUIToolCallID := tr.callToolCallID(fc.ID, fc.Name)
+
+			var startOptions []events.ToolCallStartOption
+			parentMessageID := tr.beginToolCall()
+			if parentMessageID != "" {
+				startOptions = append(startOptions, events.WithParentMessageID(parentMessageID))
+			}
+			tr.eventChan <- events.NewToolCallStartEvent(agUIToolCallID, fc.Name, startOptions...)
+			tr.startedToolCalls[agUIToolCallID] = true
+			tr.recordToolCallMessage(agUIToolCallID, parentMessageID)
+			tr.startProgress(agUIToolCallID, fc.Name, fc.Args)
 
 			if fc.Args != nil {
 				argsJSON, err := json.Marshal(fc.Args)
 				if err == nil {
-					eventChan <- events.NewToolCallArgsEvent(agUIToolCallID, string(argsJSON))
+					argsEvent := events.NewToolCallArgsEvent(agUIToolCallID, string(argsJSON))
+					if a.structuredToolEvents {
+						tr.eventChan <- &StructuredToolCallArgsEvent{ToolCallArgsEvent: argsEvent, Args: fc.Args}
+					} else {
+						tr.eventChan <- argsEvent
+					}
 				}
 			}
 		}
@@ -167,9 +479,9 @@ func (a *AGUIAdapter) translateADKEvent(
 		// Function response (tool call result)
 		if part.FunctionResponse != nil {
 			fr := part.FunctionResponse
-			agUIToolCallID, exists := toolCallMap[fr.ID]
+			agUIToolCallID, exists := tr.responseToolCallID(fr.ID, fr.Name)
 			if !exists {
-				agUIToolCallID = events.GenerateToolCallID()
+				agUIToolCallID = idGen.GenerateToolCallID()
 			}
 
 			resultStr := ""
@@ -181,11 +493,31 @@ func (a *AGUIAdapter) translateADKEvent(
 				}
 			}
 
-			eventChan <- events.NewToolCallResultEvent(messageID, agUIToolCallID, resultStr)
-			eventChan <- events.NewToolCallEndEvent(agUIToolCallID)
-			delete(startedToolCalls, agUIToolCallID)
+			// Each result is its own tool message, threaded separately from the assistant text,
+			// unless results are threaded into the assistant message
+			toolMessageID := tr.toolResultMessageID(agUIToolCallID)
+			if a.toolResultsInAssistantMessage {
+				toolMessageID = tr.toolCallMessageID(agUIToolCallID)
+			}
+			resultEvent := events.NewToolCallResultEvent(toolMessageID, agUIToolCallID, resultStr)
+			if a.structuredToolEvents {
+				tr.eventChan <- &StructuredToolCallResultEvent{ToolCallResultEvent: resultEvent, Result: fr.Response}
+			} else {
+				tr.eventChan <- resultEvent
+			}
+			tr.eventChan <- events.NewToolCallEndEvent(agUIToolCallID)
+			delete(tr.startedToolCalls, agUIToolCallID)
+			tr.toolResultsEmitted = true
+			tr.completeProgress(agUIToolCallID, fr.Name)
+		}
+
+		// Anything else (code execution, files) is surfaced rather than silently dropped
+		if summary, ok := describeUnknownPart(part); ok {
+			tr.eventChan <- events.NewCustomEvent(CustomEventUnknownPart, events.WithValue(summary))
 		}
 	}
+
+	return nil
 }
 
 // EventSender defines the interface for sending events (SSE or Connect RPC)
@@ -204,72 +536,289 @@ func (a *AGUIAdapter) RunAgentProtocol(
 	stateMgr *transport.StateManager,
 	sender EventSender,
 ) error {
+	if a.logEvents {
+		sender = &loggingSender{next: sender, requestID: transport.RequestIDFromContext(ctx), bodies: a.logEventBodies}
+	}
+
 	// Generate IDs if not provided
 	threadID := input.ThreadID
 	if threadID == "" {
-		threadID = events.GenerateThreadID()
+		threadID = idGen.GenerateThreadID()
 	}
 	runID := input.RunID
 	if runID == "" {
-		runID = events.GenerateRunID()
+		runID = idGen.GenerateRunID()
 	}
+	// Sessions, state and per-thread data are keyed by the namespaced thread ID;
+	// events carry the client's own ID
+	threadKey := transport.ThreadKey(ctx, threadID)
 
 	// Note: Validation is done in handlers before calling RunAgentProtocol
 	// This ensures fail-fast behavior and proper HTTP error codes
 
+	if a.headersToProps {
+		if headers := transport.ForwardedHeadersFromContext(ctx); headers != nil {
+			if input.ForwardedProps == nil {
+				input.ForwardedProps = make(map[string]interface{})
+			}
+			input.ForwardedProps[ForwardedPropHeaders] = headers
+		}
+	}
+
+	// Let request middleware rewrite the input (messages, state, props) before it is used
+	if err := a.preprocess(ctx, input); err != nil {
+		return sender.SendRunError(runID, err)
+	}
+
+	// A denied run, e.g. on another user's thread, touches neither the thread state nor the session
+	if err := a.authorizeRun(ctx, input, threadKey); err != nil {
+		if errors.Is(err, errForbidden) {
+			return sender.SendEvent(events.NewRunErrorEvent("forbidden", events.WithRunID(runID), events.WithErrorCode("forbidden")))
+		}
+		return sender.SendRunError(runID, err)
+	}
+
+	// Disallowed input is rejected before it costs any tokens or changes the thread state
+	// A state sync sends nothing to the model, so its messages are not checked
+	if !input.StateSyncOnly() {
+		if ended, err := a.moderate(ctx, input, runID, sender); ended {
+			return err
+		}
+	}
+
+	// A retried request replays the completed run instead of running the model again;
+	// only a request that passed the checks above (thread ownership included) may see it,
+	// and only the principal that sent the original request
+	if key := transport.IdempotencyKeyFromContext(ctx); key != "" && a.idempotency != nil {
+		recorded, replay, done, err := a.idempotency.begin(ctx, transport.PrincipalFromContext(ctx), threadKey, key)
+		if err != nil {
+			return sender.SendRunError(runID, err)
+		}
+		if replay {
+			log.Printf("[%s] Replaying completed run for idempotency key %q", transport.RequestIDFromContext(ctx), key)
+			for _, event := range recorded {
+				if err := sender.SendEvent(event); err != nil {
+					return fmt.Errorf("failed to replay event: %w", err)
+				}
+			}
+			return nil
+		}
+		recorder := &recordingSender{next: sender}
+		sender = recorder
+		defer func() {
+			done(recorder.recorded, recorder.finished)
+		}()
+	}
+
 	// Handle state persistence: merge incoming state with existing state for this thread
-	mergedState := stateMgr.Merge(threadID, input.State)
+	mergedState, removedKeys, err := stateMgr.Merge(threadKey, input.State)
+	if err != nil {
+		return sender.SendRunError(runID, err)
+	}
+
+	// A state sync returns the merged state without running the model, whatever the messages
+	if input.StateSyncOnly() {
+		return sendStateSync(threadID, runID, mergedState, sender)
+	}
 
 	// If no messages, send current state snapshot according to AG-UI protocol
+	// The snapshot already reflects any reset keys, so no STATE_DELTA is needed
 	if len(input.Messages) == 0 {
 		stateSnapshot := events.NewStateSnapshotEvent(mergedState)
+		if a.greeting != "" {
+			return a.sendGreeting(input, threadID, runID, stateSnapshot, sender)
+		}
 		return sender.SendEvent(stateSnapshot)
 	}
 
+	// Also report the result to the client's callback URL once the run ends
+	if callbackURL, ok := input.ForwardedProps[ForwardedPropCallbackURL].(string); ok {
+		if a.webhooks == nil {
+			return sender.SendRunError(runID, errors.New("callbackUrl is not enabled"))
+		}
+		collector := newWebhookCollector(sender, threadID, runID)
+		sender = collector
+		defer a.webhooks.notify(transport.RequestIDFromContext(ctx), callbackURL, collector)
+	}
+
 	// Send RUN_STARTED event
 	runStarted := events.NewRunStartedEvent(threadID, runID)
 	if err := sender.SendEvent(runStarted); err != nil {
 		return fmt.Errorf("failed to send RUN_STARTED: %w", err)
 	}
 
-	// Generate message ID for this response
-	messageID := events.GenerateMessageID()
+	// Correlate the run with upstream gateway logs
+	if requestID := transport.RequestIDFromContext(ctx); requestID != "" {
+		requestIDEvent := events.NewCustomEvent(CustomEventRequestID, events.WithValue(map[string]interface{}{
+			"requestId": requestID,
+		}))
+		if err := sender.SendEvent(requestIDEvent); err != nil {
+			return fmt.Errorf("failed to send request_id event: %w", err)
+		}
+	}
 
-	// Send TEXT_MESSAGE_START event
-	textStart := events.NewTextMessageStartEvent(messageID, events.WithRole("assistant"))
-	if err := sender.SendEvent(textStart); err != nil {
-		return fmt.Errorf("failed to send TEXT_MESSAGE_START: %w", err)
+	// Tell the client which keys were cleared via the reset key
+	if len(removedKeys) > 0 {
+		if err := sender.SendEvent(events.NewStateDeltaEvent(removeOps(removedKeys))); err != nil {
+			return fmt.Errorf("failed to send STATE_DELTA: %w", err)
+		}
 	}
 
+	// Message ID for this response, stable across retries of the run when the client names it
+	// TEXT_MESSAGE_START/END are emitted by the adapter around the assistant text
+	messageID := assistantMessageID(input)
+
 	// Run the agent and stream responses
-	eventChan, err := a.RunAgent(ctx, input, threadID, runID, messageID, "demo_user")
+	// The run is cancelled if we stop consuming its events early
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	// The run executes as the authenticated caller, so sessions and authorization tell callers apart
+	principal := transport.PrincipalFromContext(ctx)
+	eventChan, result, err := a.RunAgent(runCtx, input, threadKey, runID, messageID, principal, mergedState)
 	if err != nil {
-		// If message was started, we must send TEXT_MESSAGE_END before RUN_ERROR
-		textEnd := events.NewTextMessageEndEvent(messageID)
-		sender.SendEvent(textEnd) // Best effort, ignore error
-
-		// Send error event
 		return sender.SendRunError(runID, fmt.Errorf("agent execution failed: %w", err))
 	}
 
 	// Stream events from the adapter
-	for event := range eventChan {
-		if err := sender.SendEvent(event); err != nil {
-			return fmt.Errorf("failed to send event: %w", err)
+	// On shutdown the run is cancelled, and its remaining events still close what it has open
+	runFailed := false
+	interrupted := false
+	shutdown := a.shutdown
+stream:
+	for {
+		select {
+		case event, ok := <-eventChan:
+			if !ok {
+				break stream
+			}
+			if _, ok := event.(*events.RunErrorEvent); ok {
+				runFailed = true
+			}
+			if err := sender.SendEvent(event); err != nil {
+				// Stop the run and drain what it still sends, so its goroutine
+				// can't block forever on a full channel
+				cancelRun()
+				go drain(eventChan)
+				return fmt.Errorf("failed to send event: %w", err)
+			}
+		case <-shutdown:
+			shutdown = nil
+			interrupted = true
+			cancelRun()
 		}
 	}
 
-	// Send TEXT_MESSAGE_END event
-	textEnd := events.NewTextMessageEndEvent(messageID)
-	if err := sender.SendEvent(textEnd); err != nil {
-		return fmt.Errorf("failed to send TEXT_MESSAGE_END: %w", err)
+	// RUN_ERROR is terminal: a failed run never also finishes
+	if runFailed {
+		return nil
+	}
+	if interrupted {
+		return sendShutdown(runID, sender)
+	}
+	// The stream's hard cap is an error, unlike the agent timeout, which finishes with what was produced
+	if transport.StreamDurationExceeded(ctx) {
+		return sender.SendEvent(events.NewRunErrorEvent(transport.ErrStreamDurationExceeded.Error(),
+			events.WithRunID(runID), events.WithErrorCode(transport.ErrorCodeStreamDurationExceeded)))
+	}
+	// A cancelled run has already closed its message and tool calls; it ends as an error,
+	// best effort since the client is usually gone
+	if result.FinishReason == FinishReasonCancelled {
+		return sender.SendEvent(events.NewRunErrorEvent(ErrorCodeCancelled,
+			events.WithRunID(runID), events.WithErrorCode(ErrorCodeCancelled)))
+	}
+
+	// Leave the client with the authoritative state after tools changed it
+	if a.finalStateSnapshot {
+		if err := a.sendFinalState(threadKey, runID, mergedState, result, stateMgr, sender); err != nil {
+			return err
+		}
+	} else if a.streamStateDeltas && len(result.StateDelta) > 0 {
+		// The client already applied the deltas; keep the thread state in step with it
+		if _, _, err := stateMgr.Merge(threadKey, toolStateUpdate(result.StateDelta)); err != nil {
+			return sender.SendRunError(runID, err)
+		}
 	}
 
-	// Send RUN_FINISHED event
-	runFinished := events.NewRunFinishedEvent(threadID, runID)
+	// Send RUN_FINISHED event, with why the run ended
+	runFinished := events.NewRunFinishedEventWithOptions(threadID, runID, events.WithResult(map[string]interface{}{
+		"finishReason": result.FinishReason,
+	}))
 	if err := sender.SendEvent(runFinished); err != nil {
 		return fmt.Errorf("failed to send RUN_FINISHED: %w", err)
 	}
 
 	return nil
 }
+
+// sendFinalState merges the state set by tools into the thread state and, if the
+// thread state differs from the one the run started with, sends a STATE_SNAPSHOT
+func (a *AGUIAdapter) sendFinalState(
+	threadID, runID string,
+	initialState map[string]interface{},
+	result *RunResult,
+	stateMgr *transport.StateManager,
+	sender EventSender,
+) error {
+	finalState := stateMgr.Get(threadID)
+	if len(result.StateDelta) > 0 {
+		merged, _, err := stateMgr.Merge(threadID, toolStateUpdate(result.StateDelta))
+		if err != nil {
+			return sender.SendRunError(runID, err)
+		}
+		finalState = merged
+	}
+	if reflect.DeepEqual(finalState, initialState) {
+		return nil
+	}
+	if err := sender.SendEvent(events.NewStateSnapshotEvent(finalState)); err != nil {
+		return fmt.Errorf("failed to send STATE_SNAPSHOT: %w", err)
+	}
+	return nil
+}
+
+// collectStateDelta records session-scoped state changes from an ADK event
+// app:, user: and temp: keys belong to ADK and are not client state
+func collectStateDelta(result *RunResult, delta map[string]any) {
+	for key, value := range delta {
+		if strings.HasPrefix(key, adksession.KeyPrefixApp) ||
+			strings.HasPrefix(key, adksession.KeyPrefixUser) ||
+			strings.HasPrefix(key, adksession.KeyPrefixTemp) {
+			continue
+		}
+		if result.StateDelta == nil {
+			result.StateDelta = make(map[string]interface{})
+		}
+		result.StateDelta[key] = value
+	}
+}
+
+// sendGreeting answers an empty-messages request with the state snapshot and the configured
+// greeting, wrapped in a regular run so clients render it like any assistant message
+func (a *AGUIAdapter) sendGreeting(
+	input *RunAgentInput,
+	threadID, runID string,
+	stateSnapshot events.Event,
+	sender EventSender,
+) error {
+	messageID := idGen.GenerateMessageID()
+	greetingEvents := []events.Event{
+		events.NewRunStartedEvent(threadID, runID),
+		stateSnapshot,
+		events.NewTextMessageStartEvent(messageID, events.WithRole(input.AssistantRole(a.assistantRole))),
+		newSequencedTextMessageContentEvent(messageID, a.greeting, 0),
+		events.NewTextMessageEndEvent(messageID),
+		events.NewRunFinishedEvent(threadID, runID),
+	}
+	for _, event := range greetingEvents {
+		if err := sender.SendEvent(event); err != nil {
+			return fmt.Errorf("failed to send greeting: %w", err)
+		}
+	}
+	return nil
+}
+
+// drain discards the remaining events of a run until its channel is closed
+func drain(eventChan <-chan events.Event) {
+	for range eventChan {
+	}
+}
@@ -0,0 +1,76 @@
+package agui_adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+// Authorizer decides whether a principal may run an agent on a thread
+// It is the extension point for RBAC on top of the bearer token check
+// Returning an error denies the run with RUN_ERROR "forbidden" (403 / PermissionDenied)
+// principal is the authenticated caller (see transport.PrincipalFromContext); runs without one
+// are denied before the Authorizer is asked
+// agentName is empty when a thread is accessed outside of a run (subscribing to its run, reading its state)
+type Authorizer interface {
+	Authorize(ctx context.Context, principal, agentName, threadID string) error
+}
+
+// AllowAllAuthorizer authorizes every run
+type AllowAllAuthorizer struct{}
+
+// Authorize allows the run
+func (AllowAllAuthorizer) Authorize(context.Context, string, string, string) error {
+	return nil
+}
+
+// errForbidden denies access to a thread
+var errForbidden = errors.New("forbidden")
+
+// AuthorizeThread checks that the request's principal may access a thread outside of a run,
+// e.g. to subscribe to its run or read its state; threadID is the internal thread key
+// Like a run, it is denied without an authenticated principal, on another user's thread
+// (with SESSION_USER_ISOLATION) and when the Authorizer refuses
+func (a *AGUIAdapter) AuthorizeThread(ctx context.Context, threadID string) error {
+	principal := transport.PrincipalFromContext(ctx)
+	if principal == "" {
+		return errForbidden
+	}
+	if err := a.sessionMgr.CheckOwner(a.appName, principal, threadID); err != nil {
+		return err
+	}
+	if err := a.authorizer.Authorize(ctx, principal, "", threadID); err != nil {
+		return fmt.Errorf("%w: %v", errForbidden, err)
+	}
+	return nil
+}
+
+// authorizeRun checks that the request's principal may run the selected agent on a thread
+// threadID is the internal thread key; without an authenticated principal the run is denied
+// without asking the Authorizer
+// An allowed run claims the thread (with SESSION_USER_ISOLATION), so another user's thread is
+// denied before its state is merged, snapshotted or replayed
+// Returns errForbidden for a denied run, or the error selecting the agent
+func (a *AGUIAdapter) authorizeRun(ctx context.Context, input *RunAgentInput, threadID string) error {
+	runAgent, err := a.SelectAgent(ctx, input)
+	if err != nil {
+		return err
+	}
+	principal := transport.PrincipalFromContext(ctx)
+	if principal == "" {
+		log.Printf("[%s] Run denied on agent %q, thread %s: no authenticated principal", transport.RequestIDFromContext(ctx), runAgent.Name(), threadID)
+		return errForbidden
+	}
+	if err := a.authorizer.Authorize(ctx, principal, runAgent.Name(), threadID); err != nil {
+		log.Printf("[%s] Run denied for %q on agent %q, thread %s: %v", transport.RequestIDFromContext(ctx), principal, runAgent.Name(), threadID, err)
+		return errForbidden
+	}
+	if err := a.sessionMgr.Claim(a.appName, principal, threadID); err != nil {
+		log.Printf("[%s] Run denied for %q on thread %s: owned by another user", transport.RequestIDFromContext(ctx), principal, threadID)
+		return errForbidden
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package agui_adapter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/session"
+	"agent-go-ag-ui/internal/transport"
+)
+
+// recordingAuthorizer records the principals it is asked about and denies those in deny
+type recordingAuthorizer struct {
+	principals []string
+	deny       map[string]bool
+}
+
+func (r *recordingAuthorizer) Authorize(_ context.Context, principal, _, _ string) error {
+	r.principals = append(r.principals, principal)
+	if r.deny[principal] {
+		return errors.New("denied")
+	}
+	return nil
+}
+
+func TestAuthorizerPrincipal(t *testing.T) {
+	tests := []struct {
+		name           string
+		principal      string
+		wantForbidden  bool
+		wantPrincipals []string
+	}{
+		{"allowed principal", "alice", false, []string{"alice"}},
+		{"denied principal", "mallory", true, []string{"mallory"}},
+		{"no principal fails closed", "", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorizer := &recordingAuthorizer{deny: map[string]bool{"mallory": true}}
+			cfg := testConfig()
+			a := NewAGUIAdapter(cfg, staticAgents{scriptedAgent(t, []*genai.Part{genai.NewPartFromText("hi")})},
+				session.NewManager(session.RetryPolicy{}, true), nil, nil, authorizer, nil)
+
+			evts := runProtocol(t, a, tt.principal, userInput("thread-1", "hello"))
+
+			runErr := runError(evts)
+			if forbidden := runErr != nil && runErr.Message == "forbidden"; forbidden != tt.wantForbidden {
+				t.Errorf("forbidden = %v, want %v (events %v)", forbidden, tt.wantForbidden, eventTypes(evts))
+			}
+			if len(authorizer.principals) != len(tt.wantPrincipals) {
+				t.Fatalf("authorizer asked about %v, want %v", authorizer.principals, tt.wantPrincipals)
+			}
+			for i, principal := range tt.wantPrincipals {
+				if authorizer.principals[i] != principal {
+					t.Errorf("authorizer asked about %q, want %q", authorizer.principals[i], principal)
+				}
+			}
+		})
+	}
+}
+
+func TestRunOnAnotherUsersThread(t *testing.T) {
+	tests := []struct {
+		name  string
+		input func() *RunAgentInput
+	}{
+		{
+			name: "read with no messages",
+			input: func() *RunAgentInput {
+				return &RunAgentInput{ThreadID: "thread-1", RunID: "run-2"}
+			},
+		},
+		{
+			name: "overwrite with no messages",
+			input: func() *RunAgentInput {
+				return &RunAgentInput{ThreadID: "thread-1", RunID: "run-2", State: map[string]interface{}{"secret": "overwritten"}}
+			},
+		},
+		{
+			name: "overwrite with a state sync",
+			input: func() *RunAgentInput {
+				input := userInput("thread-1", "hello")
+				input.State = map[string]interface{}{"secret": "overwritten"}
+				input.ForwardedProps = map[string]interface{}{ForwardedPropStateSyncOnly: true}
+				return input
+			},
+		},
+		{
+			name: "run",
+			input: func() *RunAgentInput {
+				input := userInput("thread-1", "hello")
+				input.State = map[string]interface{}{"secret": "overwritten"}
+				return input
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAdapter(scriptedAgent(t, []*genai.Part{genai.NewPartFromText("Hi")}), nil)
+			stateMgr := transport.NewStateManager(0)
+			aliceInput := userInput("thread-1", "hello")
+			aliceInput.State = map[string]interface{}{"secret": "alice"}
+			if err := a.RunAgentProtocol(transport.WithPrincipal(context.Background(), "alice"), aliceInput, stateMgr, &collectingSender{}); err != nil {
+				t.Fatalf("alice's run: %v", err)
+			}
+
+			sender := &collectingSender{}
+			if err := a.RunAgentProtocol(transport.WithPrincipal(context.Background(), "bob"), tt.input(), stateMgr, sender); err != nil {
+				t.Fatalf("bob's run: %v", err)
+			}
+
+			// Bob is denied before the state is merged, so he neither sees nor changes alice's state
+			want := []events.EventType{events.EventTypeRunError}
+			if got := eventTypes(sender.events); !reflect.DeepEqual(got, want) {
+				t.Fatalf("bob's events = %v, want %v", got, want)
+			}
+			if msg := runError(sender.events).Message; msg != "forbidden" {
+				t.Errorf("RUN_ERROR = %q, want forbidden", msg)
+			}
+			if got, want := stateMgr.Get("thread-1"), map[string]interface{}{"secret": "alice"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("alice's state = %v, want %v", got, want)
+			}
+		})
+	}
+}
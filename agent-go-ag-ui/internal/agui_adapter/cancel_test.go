@@ -0,0 +1,143 @@
+package agui_adapter
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/adk/agent"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+// cancelOnSender cancels the run once an event of eventType reached the client
+type cancelOnSender struct {
+	collectingSender
+	eventType events.EventType
+	cancel    context.CancelFunc
+}
+
+func (c *cancelOnSender) SendEvent(event events.Event) error {
+	if event.Type() == c.eventType {
+		c.cancel()
+	}
+	return c.collectingSender.SendEvent(event)
+}
+
+func TestCancelMidStreamEndsWithRunError(t *testing.T) {
+	// The second step blocks until the run is cancelled
+	a := newTestAdapter(scriptedAgent(t, []*genai.Part{genai.NewPartFromText("partial")}, nil), nil)
+	ctx, cancel := context.WithCancel(transport.WithPrincipal(context.Background(), "alice"))
+	defer cancel()
+	sender := &cancelOnSender{eventType: events.EventTypeTextMessageContent, cancel: cancel}
+
+	if err := a.RunAgentProtocol(ctx, userInput("thread-1", "hello"), transport.NewStateManager(0), sender); err != nil {
+		t.Fatalf("RunAgentProtocol: %v", err)
+	}
+
+	evts := withoutType(sender.events, events.EventTypeCustom)
+	want := []events.EventType{
+		events.EventTypeRunStarted,
+		events.EventTypeTextMessageStart,
+		events.EventTypeTextMessageContent,
+		events.EventTypeTextMessageEnd,
+		events.EventTypeRunError,
+	}
+	got := eventTypes(evts)
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+	if runErr := runError(evts); runErr.Code == nil || *runErr.Code != ErrorCodeCancelled {
+		t.Errorf("RUN_ERROR code = %v, want %q", runErr.Code, ErrorCodeCancelled)
+	}
+}
+
+// slowToolAgent calls get_time and executes it as a slow tool that only returns when its context is done
+// cancelled is set if the tool saw the run's cancellation
+func slowToolAgent(t *testing.T, cancelled *atomic.Bool) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "slow_tool_agent",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(yield func(*adksession.Event, error) bool) {
+				call := adksession.NewEvent(ctx.InvocationID())
+				call.Author = "slow_tool_agent"
+				call.Content = genai.NewContentFromParts([]*genai.Part{
+					{FunctionCall: &genai.FunctionCall{ID: "call-1", Name: "get_time", Args: map[string]any{}}},
+				}, genai.RoleModel)
+				if !yield(call, nil) {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					cancelled.Store(true)
+					yield(nil, ctx.Err())
+				case <-time.After(10 * time.Second):
+					yield(nil, errors.New("tool was not cancelled"))
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+// toolCallErrors returns the tool_call_error values of a run
+func toolCallErrors(evts []events.Event) []map[string]interface{} {
+	var values []map[string]interface{}
+	for _, event := range evts {
+		if e, ok := event.(*events.CustomEvent); ok && e.Name == CustomEventToolCallError {
+			values = append(values, e.Value.(map[string]interface{}))
+		}
+	}
+	return values
+}
+
+func TestCancelDuringSlowToolEndsToolCall(t *testing.T) {
+	var toolCancelled atomic.Bool
+	a := newTestAdapter(slowToolAgent(t, &toolCancelled), nil)
+	ctx, cancel := context.WithCancel(transport.WithPrincipal(context.Background(), "alice"))
+	defer cancel()
+	sender := &cancelOnSender{eventType: events.EventTypeToolCallStart, cancel: cancel}
+
+	if err := a.RunAgentProtocol(ctx, userInput("thread-1", "what time is it?"), transport.NewStateManager(0), sender); err != nil {
+		t.Fatalf("RunAgentProtocol: %v", err)
+	}
+
+	if !toolCancelled.Load() {
+		t.Error("the tool didn't see the run's cancellation")
+	}
+	errs := toolCallErrors(sender.events)
+	if len(errs) != 1 || errs[0]["toolCallId"] != "call-1" || errs[0]["error"] != string(FinishReasonCancelled) {
+		t.Fatalf("tool_call_error events = %v, want one cancelled error for call-1", errs)
+	}
+
+	evts := withoutType(sender.events, events.EventTypeCustom)
+	want := []events.EventType{
+		events.EventTypeRunStarted,
+		events.EventTypeToolCallStart,
+		events.EventTypeToolCallArgs,
+		events.EventTypeToolCallEnd,
+		events.EventTypeRunError,
+	}
+	if got := eventTypes(evts); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if runErr := runError(evts); runErr.Code == nil || *runErr.Code != ErrorCodeCancelled {
+		t.Errorf("RUN_ERROR code = %v, want %q", runErr.Code, ErrorCodeCancelled)
+	}
+}
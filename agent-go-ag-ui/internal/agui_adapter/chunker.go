@@ -0,0 +1,54 @@
+package agui_adapter
+
+import "strings"
+
+// maxSentenceBuffer is the size at which pending text is released even without a sentence boundary
+const maxSentenceBuffer = 500
+
+// sentenceChunker buffers streamed text and releases it at sentence boundaries,
+// for frontends (e.g. TTS) that work better with whole sentences than tokens
+type sentenceChunker struct {
+	pending strings.Builder
+}
+
+// push adds streamed text and returns the complete sentences now ready to send ("" if none)
+// A boundary is a newline, or '.', '?' or '!' followed by whitespace; a trailing '.' is held
+// back until the next chunk shows it isn't part of e.g. "3.14"
+func (c *sentenceChunker) push(delta string) string {
+	c.pending.WriteString(delta)
+	text := c.pending.String()
+
+	end := lastSentenceEnd(text)
+	if end == 0 && len(text) < maxSentenceBuffer {
+		return ""
+	}
+	if end == 0 {
+		end = len(text)
+	}
+
+	c.pending.Reset()
+	c.pending.WriteString(text[end:])
+	return text[:end]
+}
+
+// flush returns and clears any pending text
+func (c *sentenceChunker) flush() string {
+	text := c.pending.String()
+	c.pending.Reset()
+	return text
+}
+
+// lastSentenceEnd returns the index just past the last sentence boundary in text, or 0
+func lastSentenceEnd(text string) int {
+	for i := len(text) - 1; i >= 0; i-- {
+		switch text[i] {
+		case '\n':
+			return i + 1
+		case ' ', '\t':
+			if i > 0 && strings.IndexByte(".?!", text[i-1]) >= 0 {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package agui_adapter
+
+import "google.golang.org/genai"
+
+// CustomEventCitations is the CUSTOM event name carrying grounding sources
+const CustomEventCitations = "citations"
+
+// Citation is a source the model used to ground its answer
+// Sent as {"citations": [Citation, ...]} in a CUSTOM "citations" event
+type Citation struct {
+	Title string `json:"title,omitempty"`
+	URI   string `json:"uri"`
+	// Snippet is the first part of the answer supported by this source, if known
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// extractCitations converts grounding metadata into citations, one per source with a URI
+func extractCitations(metadata *genai.GroundingMetadata) []Citation {
+	if metadata == nil {
+		return nil
+	}
+
+	// Map each chunk to the first answer segment it supports
+	snippets := make(map[int]string)
+	for _, support := range metadata.GroundingSupports {
+		if support == nil || support.Segment == nil {
+			continue
+		}
+		for _, idx := range support.GroundingChunkIndices {
+			if _, exists := snippets[int(idx)]; !exists {
+				snippets[int(idx)] = support.Segment.Text
+			}
+		}
+	}
+
+	var citations []Citation
+	for i, chunk := range metadata.GroundingChunks {
+		if chunk == nil {
+			continue
+		}
+
+		var citation Citation
+		switch {
+		case chunk.Web != nil:
+			citation = Citation{Title: chunk.Web.Title, URI: chunk.Web.URI}
+		case chunk.RetrievedContext != nil:
+			citation = Citation{Title: chunk.RetrievedContext.Title, URI: chunk.RetrievedContext.URI}
+		default:
+			continue
+		}
+		if citation.URI == "" {
+			continue
+		}
+
+		citation.Snippet = snippets[i]
+		citations = append(citations, citation)
+	}
+
+	return citations
+}
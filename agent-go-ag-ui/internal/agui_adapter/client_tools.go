@@ -0,0 +1,51 @@
+package agui_adapter
+
+import (
+	"fmt"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+// validateClientTools checks each entry of the request's tools is an object with a name,
+// and an optional description string and parameters object (a JSON Schema)
+func validateClientTools(tools []interface{}) error {
+	names := make(map[string]bool, len(tools))
+	for i, item := range tools {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("tool at index %d must be an object, got %s", i, jsonValueKind(item))
+		}
+		name, _ := entry["name"].(string)
+		if name == "" {
+			return fmt.Errorf("tool at index %d missing required field 'name'", i)
+		}
+		if names[name] {
+			return fmt.Errorf("tool %q is declared twice", name)
+		}
+		names[name] = true
+		if description, exists := entry["description"]; exists && description != nil {
+			if _, ok := description.(string); !ok {
+				return fmt.Errorf("tool %q has invalid 'description' type (expected string, got %s)", name, jsonValueKind(description))
+			}
+		}
+		if parameters, exists := entry["parameters"]; exists && parameters != nil {
+			if _, ok := parameters.(map[string]interface{}); !ok {
+				return fmt.Errorf("tool %q has invalid 'parameters' type (expected object, got %s)", name, jsonValueKind(parameters))
+			}
+		}
+	}
+	return nil
+}
+
+// clientTools converts the validated request tools for the run context
+func clientTools(tools []interface{}) []transport.ClientTool {
+	result := make([]transport.ClientTool, 0, len(tools))
+	for _, item := range tools {
+		entry, _ := item.(map[string]interface{})
+		name, _ := entry["name"].(string)
+		description, _ := entry["description"].(string)
+		parameters, _ := entry["parameters"].(map[string]interface{})
+		result = append(result, transport.ClientTool{Name: name, Description: description, Parameters: parameters})
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package agui_adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// genaiRoles maps AG-UI message roles to genai content roles
+// genai only has user and model turns: assistant output is a model turn, while
+// system/developer instructions and tool results are sent on the user side
+var genaiRoles = map[string]genai.Role{
+	"user":      genai.RoleUser,
+	"assistant": genai.RoleModel,
+	"system":    genai.RoleUser,
+	"developer": genai.RoleUser,
+	"tool":      genai.RoleUser,
+}
+
+// genaiRole returns the genai role for an AG-UI role, defaulting to user
+func genaiRole(aguiRole string) genai.Role {
+	if role, ok := genaiRoles[aguiRole]; ok {
+		return role
+	}
+	return genai.RoleUser
+}
+
+// buildRunContent builds the genai content for the new turn of a run
+// Trailing tool messages (client-side tool results) are sent as function responses,
+// otherwise the last user message is used; history is the session the run continues (may be nil)
+// Returns nil content if there is no usable message
+func buildRunContent(ctx context.Context, messages []map[string]interface{}, history adksession.Events, images *imageLoader) (*genai.Content, error) {
+	parts, err := toolResponseParts(messages, history)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) > 0 {
+		return genai.NewContentFromParts(parts, genaiRole("tool")), nil
+	}
+
+	// Find last user message
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		role, ok := msg["role"].(string)
+		if !ok || role != "user" {
+			continue
+		}
+		switch content := msg["content"].(type) {
+		case string:
+			if content != "" {
+				return genai.NewContentFromText(content, genaiRole(role)), nil
+			}
+		case []interface{}:
+			parts, err := contentParts(ctx, content, images)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			if len(parts) > 0 {
+				return genai.NewContentFromParts(parts, genaiRole(role)), nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// systemInstructions returns the content of the system and developer messages, in order
+// They are validated to be non-empty strings
+func systemInstructions(messages []map[string]interface{}) []string {
+	var instructions []string
+	for _, msg := range messages {
+		role, _ := msg["role"].(string)
+		if role != "system" && role != "developer" {
+			continue
+		}
+		if content, _ := msg["content"].(string); strings.TrimSpace(content) != "" {
+			instructions = append(instructions, content)
+		}
+	}
+	return instructions
+}
+
+// contentParts converts multimodal message content into genai parts
+// Supported items: {"type":"text","text"}, {"type":"binary","mimeType","data"|"url"}
+// and {"type":"image_url","image_url":{"url"}}; other item types are skipped
+func contentParts(ctx context.Context, content []interface{}, images *imageLoader) ([]*genai.Part, error) {
+	parts := make([]*genai.Part, 0, len(content))
+	for i, item := range content {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		itemType, _ := fields["type"].(string)
+		switch itemType {
+		case "text":
+			if text, _ := fields["text"].(string); text != "" {
+				parts = append(parts, genai.NewPartFromText(text))
+			}
+		case "binary":
+			mimeType, _ := fields["mimeType"].(string)
+			source, _ := fields["data"].(string)
+			if source == "" {
+				source, _ = fields["url"].(string)
+			}
+			if source == "" {
+				return nil, fmt.Errorf("content item %d: binary content needs 'data' or 'url'", i)
+			}
+			part, err := images.part(ctx, source, mimeType)
+			if err != nil {
+				return nil, fmt.Errorf("content item %d: %w", i, err)
+			}
+			parts = append(parts, part)
+		case "image_url":
+			source, _ := fields["image_url"].(string)
+			if imageURL, ok := fields["image_url"].(map[string]interface{}); ok {
+				source, _ = imageURL["url"].(string)
+			}
+			if source == "" {
+				return nil, fmt.Errorf("content item %d: image_url content needs a 'url'", i)
+			}
+			part, err := images.part(ctx, source, "")
+			if err != nil {
+				return nil, fmt.Errorf("content item %d: %w", i, err)
+			}
+			parts = append(parts, part)
+		}
+	}
+	return parts, nil
+}
+
+// trailingToolMessages returns the trailing run of tool messages (client-side tool results)
+func trailingToolMessages(messages []map[string]interface{}) []map[string]interface{} {
+	start := len(messages)
+	for start > 0 {
+		if role, _ := messages[start-1]["role"].(string); role != "tool" {
+			break
+		}
+		start--
+	}
+	return messages[start:]
+}
+
+// messageToolCallID returns the call a tool message answers, in either spelling
+func messageToolCallID(msg map[string]interface{}) string {
+	if toolCallID, _ := msg["toolCallId"].(string); toolCallID != "" {
+		return toolCallID
+	}
+	toolCallID, _ := msg["tool_call_id"].(string)
+	return toolCallID
+}
+
+// toolCallNames maps tool call IDs to their function names, from the toolCalls of the
+// assistant messages and from the function calls recorded in the session (history may be nil)
+func toolCallNames(messages []map[string]interface{}, history adksession.Events) map[string]string {
+	names := make(map[string]string)
+	if history != nil {
+		for event := range history.All() {
+			if event == nil || event.Content == nil {
+				continue
+			}
+			for _, part := range event.Content.Parts {
+				if part.FunctionCall != nil && part.FunctionCall.ID != "" {
+					names[part.FunctionCall.ID] = part.FunctionCall.Name
+				}
+			}
+		}
+	}
+	for _, msg := range messages {
+		if role, _ := msg["role"].(string); role != "assistant" {
+			continue
+		}
+		toolCalls, ok := msg["toolCalls"].([]interface{})
+		if !ok {
+			toolCalls, _ = msg["tool_calls"].([]interface{})
+		}
+		for _, item := range toolCalls {
+			call, _ := item.(map[string]interface{})
+			id, _ := call["id"].(string)
+			function, _ := call["function"].(map[string]interface{})
+			if name, _ := function["name"].(string); id != "" && name != "" {
+				names[id] = name
+			}
+		}
+	}
+	return names
+}
+
+// toolResponseParts converts the trailing run of tool messages into function response parts
+// Each result is matched to its call by toolCallId; the function name is the message's `name`
+// or, since AG-UI clients usually omit it, the name of the call (see toolCallNames)
+func toolResponseParts(messages []map[string]interface{}, history adksession.Events) ([]*genai.Part, error) {
+	toolMessages := trailingToolMessages(messages)
+	if len(toolMessages) == 0 {
+		return nil, nil
+	}
+
+	names := toolCallNames(messages, history)
+	parts := make([]*genai.Part, 0, len(toolMessages))
+	for _, msg := range toolMessages {
+		toolCallID := messageToolCallID(msg)
+		name, _ := msg["name"].(string)
+		if name == "" {
+			name = names[toolCallID]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("tool result for %q answers no known tool call", toolCallID)
+		}
+		part := genai.NewPartFromFunctionResponse(name, toolResponse(msg["content"]))
+		part.FunctionResponse.ID = toolCallID
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// toolResponse converts tool message content into a function response payload
+// JSON object strings are decoded as-is, anything else is wrapped under "output"
+func toolResponse(content interface{}) map[string]any {
+	if text, ok := content.(string); ok {
+		var response map[string]any
+		if err := json.Unmarshal([]byte(text), &response); err == nil && response != nil {
+			return response
+		}
+	}
+	return map[string]any{"output": content}
+}
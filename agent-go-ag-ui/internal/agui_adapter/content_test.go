@@ -0,0 +1,113 @@
+package agui_adapter
+
+import (
+	"context"
+	"iter"
+	"strings"
+	"testing"
+
+	"google.golang.org/adk/agent"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+func TestGenaiRole(t *testing.T) {
+	tests := []struct {
+		aguiRole string
+		want     genai.Role
+	}{
+		{"user", genai.RoleUser},
+		{"assistant", genai.RoleModel},
+		{"system", genai.RoleUser},
+		{"developer", genai.RoleUser},
+		{"tool", genai.RoleUser},
+		{"unknown", genai.RoleUser},
+		{"", genai.RoleUser},
+	}
+	for _, tt := range tests {
+		if got := genaiRole(tt.aguiRole); got != tt.want {
+			t.Errorf("genaiRole(%q) = %q, want %q", tt.aguiRole, got, tt.want)
+		}
+	}
+}
+
+func TestBuildRunContentRoles(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []map[string]interface{}
+		want     genai.Role
+	}{
+		{
+			name: "user message",
+			messages: []map[string]interface{}{
+				{"id": "1", "role": "user", "content": "hi"},
+			},
+			want: genai.RoleUser,
+		},
+		{
+			name: "tool results",
+			messages: []map[string]interface{}{
+				{"id": "1", "role": "user", "content": "what time is it?"},
+				{"id": "2", "role": "tool", "name": "get_time", "toolCallId": "call-1", "content": "12:00"},
+			},
+			want: genai.RoleUser,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := buildRunContent(context.Background(), tt.messages, nil, nil)
+			if err != nil {
+				t.Fatalf("buildRunContent: %v", err)
+			}
+			if content == nil {
+				t.Fatal("buildRunContent returned no content")
+			}
+			if content.Role != string(tt.want) {
+				t.Errorf("role = %q, want %q", content.Role, tt.want)
+			}
+		})
+	}
+}
+
+// echoAgent answers with the text of the user content it was run with
+func echoAgent(t *testing.T) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "echo_agent",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(yield func(*adksession.Event, error) bool) {
+				var text strings.Builder
+				if content := ctx.UserContent(); content != nil {
+					for _, part := range content.Parts {
+						text.WriteString(part.Text)
+					}
+				}
+				event := adksession.NewEvent(ctx.InvocationID())
+				event.Author = "echo_agent"
+				event.Content = genai.NewContentFromText(text.String(), genai.RoleModel)
+				yield(event, nil)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+func TestArrayUserContentReachesTheAgent(t *testing.T) {
+	a := newTestAdapter(echoAgent(t), nil)
+	input := userInput("thread-1", "")
+	input.Messages[0]["content"] = []interface{}{
+		map[string]interface{}{"type": "text", "text": "Hello, "},
+		map[string]interface{}{"type": "text", "text": "world."},
+	}
+	evts := runProtocol(t, a, "alice", input)
+
+	if runErr := runError(evts); runErr != nil {
+		t.Fatalf("unexpected RUN_ERROR: %s", runErr.Message)
+	}
+	if got := assistantText(evts); got != "Hello, world." {
+		t.Errorf("agent saw %q, want the array's text parts", got)
+	}
+}
@@ -0,0 +1,69 @@
+package agui_adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// redactedEventFields are the fields that carry message text or tool data, masked in
+// event logs unless bodies are enabled; event types and IDs are always kept
+var redactedEventFields = map[events.EventType][]string{
+	events.EventTypeTextMessageContent:         {"delta"},
+	events.EventTypeTextMessageChunk:           {"delta"},
+	events.EventTypeThinkingTextMessageContent: {"delta"},
+	events.EventTypeToolCallArgs:               {"delta", "args"},
+	events.EventTypeToolCallChunk:              {"delta"},
+	events.EventTypeToolCallResult:             {"content", "result"},
+}
+
+// loggingSender logs every event before passing it on
+type loggingSender struct {
+	next      EventSender
+	requestID string
+	// bodies logs message text and tool data unredacted
+	bodies bool
+}
+
+func (l *loggingSender) SendEvent(event events.Event) error {
+	log.Printf("[%s] event %s", l.requestID, formatEventForLog(event, l.bodies))
+	return l.next.SendEvent(event)
+}
+
+func (l *loggingSender) SendRunError(runID string, err error) error {
+	log.Printf("[%s] event RUN_ERROR runId=%s", l.requestID, runID)
+	return l.next.SendRunError(runID, err)
+}
+
+// formatEventForLog renders an event as JSON, masking its redacted fields unless bodies is set
+func formatEventForLog(event events.Event, bodies bool) string {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return string(event.Type())
+	}
+	fields := redactedEventFields[event.Type()]
+	if bodies || len(fields) == 0 {
+		return string(data)
+	}
+
+	var eventMap map[string]interface{}
+	if err := json.Unmarshal(data, &eventMap); err != nil {
+		return string(event.Type())
+	}
+	for _, field := range fields {
+		switch value := eventMap[field].(type) {
+		case nil:
+		case string:
+			eventMap[field] = fmt.Sprintf("[redacted %d bytes]", len(value))
+		default:
+			eventMap[field] = "[redacted]"
+		}
+	}
+	data, err = json.Marshal(eventMap)
+	if err != nil {
+		return string(event.Type())
+	}
+	return string(data)
+}
@@ -0,0 +1,62 @@
+package agui_adapter
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/genai"
+)
+
+// FinishReason explains why a run ended successfully, sent in the RUN_FINISHED result
+// Failed runs end with RUN_ERROR instead
+type FinishReason string
+
+const (
+	// FinishReasonStop is a normal, complete response
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonMaxTokens means the response was truncated at the output token limit
+	FinishReasonMaxTokens FinishReason = "max_tokens"
+	// FinishReasonContentFilter means the model stopped on a safety or content policy
+	FinishReasonContentFilter FinishReason = "content_filter"
+	// FinishReasonTimeout means the run hit the agent timeout; the response may be partial
+	FinishReasonTimeout FinishReason = "timeout"
+	// FinishReasonCancelled means the client went away before the run completed
+	// It is only reported internally: a cancelled run ends with RUN_ERROR ErrorCodeCancelled
+	FinishReasonCancelled FinishReason = "cancelled"
+)
+
+// ErrorCodeCancelled is the RUN_ERROR code of a run cancelled before it completed
+const ErrorCodeCancelled = "cancelled"
+
+// RunResult describes how a run ended
+// It is only valid once the run's event channel has been closed
+type RunResult struct {
+	FinishReason FinishReason
+	// StateDelta holds the session-scoped state keys the agent's tools set during the run
+	StateDelta map[string]interface{}
+}
+
+// finishReasonFromModel maps a model finish reason onto a run finish reason
+func finishReasonFromModel(reason genai.FinishReason) FinishReason {
+	switch reason {
+	case genai.FinishReasonMaxTokens:
+		return FinishReasonMaxTokens
+	case genai.FinishReasonSafety, genai.FinishReasonBlocklist, genai.FinishReasonProhibitedContent,
+		genai.FinishReasonSPII, genai.FinishReasonRecitation, genai.FinishReasonImageSafety:
+		return FinishReasonContentFilter
+	default:
+		return FinishReasonStop
+	}
+}
+
+// finishReasonFromContext reports whether the run context ended the run, and why
+func finishReasonFromContext(ctx context.Context) (FinishReason, bool) {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return FinishReasonTimeout, true
+	case errors.Is(ctx.Err(), context.Canceled):
+		return FinishReasonCancelled, true
+	default:
+		return "", false
+	}
+}
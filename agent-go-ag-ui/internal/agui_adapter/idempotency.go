@@ -0,0 +1,144 @@
+package agui_adapter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// maxIdempotentRuns caps the completed runs kept for replay; the oldest is evicted
+const maxIdempotentRuns = 1000
+
+// idempotencyCache keeps the events of completed runs by idempotency key, so a retried
+// request replays the original result instead of running the model again
+// A retry arriving while the first request still runs waits for it rather than running again
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotentRun
+	ttl     time.Duration
+}
+
+// idempotentRun is a run in flight, or the recorded event stream of one that reached RUN_FINISHED
+type idempotentRun struct {
+	// done is closed once the run ended; until then events and expiresAt are unset
+	done      chan struct{}
+	completed bool
+	events    []events.Event
+	expiresAt time.Time
+}
+
+// newIdempotencyCache creates a cache keeping runs for ttl, or nil if ttl is zero (disabled)
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &idempotencyCache{
+		entries: make(map[string]*idempotentRun),
+		ttl:     ttl,
+	}
+}
+
+// idempotencyCacheKey scopes a client key to its principal and thread, so reusing a key on another
+// thread runs again, and another principal sending the same key never gets the original caller's run
+// threadID is the internal thread key, so keys of different namespaces never meet
+func idempotencyCacheKey(principal, threadID, key string) string {
+	return principal + "\x00" + threadID + "\x00" + key
+}
+
+// begin looks up a principal's key before a run
+// If a completed run is recorded, its events are returned with replay set
+// Otherwise the caller runs, and must call done with the run's events and whether it finished;
+// finished runs are kept for replay, failed ones let the next retry run again
+// A run already in flight under the key is waited for first, until ctx ends
+func (c *idempotencyCache) begin(ctx context.Context, principal, threadID, key string) ([]events.Event, bool, func([]events.Event, bool), error) {
+	cacheKey := idempotencyCacheKey(principal, threadID, key)
+	for {
+		c.mu.Lock()
+		entry, exists := c.entries[cacheKey]
+		if exists && entry.completed && time.Now().After(entry.expiresAt) {
+			delete(c.entries, cacheKey)
+			exists = false
+		}
+		if !exists {
+			entry = &idempotentRun{done: make(chan struct{})}
+			c.sweep()
+			c.entries[cacheKey] = entry
+			c.mu.Unlock()
+			return nil, false, func(recorded []events.Event, finished bool) {
+				c.finish(cacheKey, entry, recorded, finished)
+			}, nil
+		}
+		if entry.completed {
+			c.mu.Unlock()
+			return entry.events, true, nil, nil
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, false, nil, ctx.Err()
+		}
+	}
+}
+
+// finish records the outcome of a run started by begin and releases the requests waiting on it
+func (c *idempotencyCache) finish(cacheKey string, entry *idempotentRun, recorded []events.Event, finished bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if finished {
+		entry.completed = true
+		entry.events = recorded
+		entry.expiresAt = time.Now().Add(c.ttl)
+	} else if c.entries[cacheKey] == entry {
+		delete(c.entries, cacheKey)
+	}
+	close(entry.done)
+}
+
+// sweep drops expired runs and, when full, the completed run expiring first
+// Runs in flight are never evicted, so their waiters aren't left behind
+// Callers hold c.mu
+func (c *idempotencyCache) sweep() {
+	now := time.Now()
+	var oldestKey string
+	var oldestExpiry time.Time
+	for k, entry := range c.entries {
+		if !entry.completed {
+			continue
+		}
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+			continue
+		}
+		if oldestKey == "" || entry.expiresAt.Before(oldestExpiry) {
+			oldestKey, oldestExpiry = k, entry.expiresAt
+		}
+	}
+	if len(c.entries) >= maxIdempotentRuns && oldestKey != "" {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// recordingSender passes events on while recording them for replay
+type recordingSender struct {
+	next     EventSender
+	recorded []events.Event
+	// finished is set once RUN_FINISHED went through; failed runs are not replayed
+	finished bool
+}
+
+func (r *recordingSender) SendEvent(event events.Event) error {
+	r.recorded = append(r.recorded, event)
+	if _, ok := event.(*events.RunFinishedEvent); ok {
+		r.finished = true
+	}
+	return r.next.SendEvent(event)
+}
+
+func (r *recordingSender) SendRunError(runID string, err error) error {
+	return r.next.SendRunError(runID, err)
+}
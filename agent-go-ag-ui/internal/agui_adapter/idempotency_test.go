@@ -0,0 +1,191 @@
+package agui_adapter
+
+import (
+	"context"
+	"iter"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/adk/agent"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/session"
+	"agent-go-ag-ui/internal/transport"
+)
+
+// countingAgent answers every run with "hi" and counts its invocations
+func countingAgent(t *testing.T, runs *atomic.Int32) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "counting_agent",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(yield func(*adksession.Event, error) bool) {
+				runs.Add(1)
+				event := adksession.NewEvent(ctx.InvocationID())
+				event.Author = "counting_agent"
+				event.Content = genai.NewContentFromParts([]*genai.Part{genai.NewPartFromText("hi")}, genai.RoleModel)
+				yield(event, nil)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+func TestIdempotentRetryIsAuthorizedBeforeReplay(t *testing.T) {
+	var runs atomic.Int32
+	cfg := testConfig()
+	cfg.IdempotencyTTL = time.Minute
+	authorizer := &recordingAuthorizer{deny: map[string]bool{"mallory": true}}
+	a := NewAGUIAdapter(cfg, staticAgents{countingAgent(t, &runs)},
+		session.NewManager(session.RetryPolicy{}, false), nil, nil, authorizer, nil)
+
+	run := func(principal, namespace string) []events.Event {
+		ctx := transport.WithIdempotencyKey(transport.WithPrincipal(context.Background(), principal), "key-1")
+		if namespace != "" {
+			ctx = transport.WithThreadNamespace(ctx, namespace)
+		}
+		sender := &collectingSender{}
+		if err := a.RunAgentProtocol(ctx, userInput("thread-1", "hello"), transport.NewStateManager(0), sender); err != nil {
+			t.Fatalf("RunAgentProtocol: %v", err)
+		}
+		return sender.events
+	}
+
+	first := run("alice", "")
+	if retry := run("alice", ""); len(retry) != len(first) || runs.Load() != 1 {
+		t.Errorf("retry sent %d events after %d model runs, want a replay of %d", len(retry), runs.Load(), len(first))
+	}
+
+	denied := run("mallory", "")
+	if runErr := runError(denied); runErr == nil || runErr.Message != "forbidden" {
+		t.Errorf("denied retry got %v, want RUN_ERROR forbidden", eventTypes(denied))
+	}
+
+	run("alice", "tenant-b")
+	if runs.Load() != 2 {
+		t.Errorf("model ran %d times, want the other namespace's request to run again", runs.Load())
+	}
+}
+
+// privateAgent answers each run with a text naming the principal it runs as
+func privateAgent(t *testing.T) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "private_agent",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(yield func(*adksession.Event, error) bool) {
+				event := adksession.NewEvent(ctx.InvocationID())
+				event.Author = "private_agent"
+				event.Content = genai.NewContentFromText(ctx.Session().UserID()+" private answer", genai.RoleModel)
+				yield(event, nil)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+func TestIdempotentRunIsNotReplayedToAnotherPrincipal(t *testing.T) {
+	tests := []struct {
+		name          string
+		isolateUsers  bool
+		wantForbidden bool
+	}{
+		// Bob may use alice's thread, but runs it himself rather than getting her replay
+		{name: "shared threads", isolateUsers: false},
+		// Bob may not use alice's thread at all
+		{name: "user isolation", isolateUsers: true, wantForbidden: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.IdempotencyTTL = time.Minute
+			a := NewAGUIAdapter(cfg, staticAgents{privateAgent(t)},
+				session.NewManager(session.RetryPolicy{}, tt.isolateUsers), nil, nil, nil, nil)
+			stateMgr := transport.NewStateManager(0)
+
+			run := func(principal string) []events.Event {
+				ctx := transport.WithIdempotencyKey(transport.WithPrincipal(context.Background(), principal), "key-1")
+				sender := &collectingSender{}
+				if err := a.RunAgentProtocol(ctx, userInput("thread-1", "hello"), stateMgr, sender); err != nil {
+					t.Fatalf("RunAgentProtocol: %v", err)
+				}
+				return sender.events
+			}
+
+			if text := assistantText(run("alice")); text != "alice private answer" {
+				t.Fatalf("alice's answer = %q", text)
+			}
+			bob := run("bob")
+			if text := assistantText(bob); strings.Contains(text, "alice") {
+				t.Errorf("bob got %q, alice's recorded run", text)
+			}
+			runErr := runError(bob)
+			if forbidden := runErr != nil && runErr.Message == "forbidden"; forbidden != tt.wantForbidden {
+				t.Errorf("bob forbidden = %v, want %v (events %v)", forbidden, tt.wantForbidden, eventTypes(bob))
+			}
+			if !tt.wantForbidden && assistantText(bob) != "bob private answer" {
+				t.Errorf("bob's answer = %q, want his own run", assistantText(bob))
+			}
+		})
+	}
+}
+
+func TestIdempotencyCacheWaitsForRunInFlight(t *testing.T) {
+	tests := []struct {
+		name       string
+		finished   bool
+		wantReplay bool
+	}{
+		{"first run finishes", true, true},
+		{"first run fails", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newIdempotencyCache(time.Minute)
+			ctx := context.Background()
+
+			_, replay, done, err := c.begin(ctx, "alice", "thread-1", "key-1")
+			if err != nil || replay {
+				t.Fatalf("first begin: replay %v, err %v", replay, err)
+			}
+
+			type outcome struct {
+				recorded []events.Event
+				replay   bool
+			}
+			second := make(chan outcome)
+			go func() {
+				recorded, replay, done, err := c.begin(ctx, "alice", "thread-1", "key-1")
+				if err != nil {
+					t.Errorf("second begin: %v", err)
+				}
+				if done != nil {
+					done(nil, false)
+				}
+				second <- outcome{recorded, replay}
+			}()
+
+			select {
+			case <-second:
+				t.Fatal("duplicate didn't wait for the run in flight")
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			done([]events.Event{events.NewRunFinishedEvent("thread-1", "run-1")}, tt.finished)
+			got := <-second
+			if got.replay != tt.wantReplay || (tt.wantReplay && len(got.recorded) != 1) {
+				t.Errorf("duplicate replay = %v with %d events, want %v", got.replay, len(got.recorded), tt.wantReplay)
+			}
+		})
+	}
+}
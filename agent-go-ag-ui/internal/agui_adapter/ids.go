@@ -0,0 +1,10 @@
+package agui_adapter
+
+import "github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+
+// IDGenerator generates thread, run, message and tool call IDs
+type IDGenerator = events.IDGenerator
+
+// idGen generates all IDs emitted by the adapter
+// Tests can replace it with a deterministic generator to assert exact event sequences
+var idGen IDGenerator = events.NewDefaultIDGenerator()
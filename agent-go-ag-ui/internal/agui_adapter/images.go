@@ -0,0 +1,164 @@
+package agui_adapter
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// imageMIMETypes are the image formats accepted by the model
+var imageMIMETypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+	"image/heic": true,
+	"image/heif": true,
+}
+
+// errNonPublicImageURL rejects image URLs that resolve to internal addresses
+var errNonPublicImageURL = errors.New("image URL must resolve to a public address")
+
+// imageLoader normalizes the image forms clients send (data URL, plain base64, http(s) URL)
+// into genai inline data
+type imageLoader struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// newImageLoader creates an image loader
+// Images larger than maxBytes are rejected; a zero fetchTimeout disables fetching URLs
+// Like webhooks, fetches only connect to public addresses, however the URL resolves or redirects
+func newImageLoader(maxBytes int64, fetchTimeout time.Duration) *imageLoader {
+	var client *http.Client
+	if fetchTimeout > 0 {
+		client = &http.Client{
+			Timeout:   fetchTimeout,
+			Transport: publicOnlyTransport(fetchTimeout, errNonPublicImageURL),
+		}
+	}
+	return &imageLoader{
+		client:   client,
+		maxBytes: maxBytes,
+	}
+}
+
+// part converts an image source into an inline data part
+// mimeType is the client-declared type; it may be empty when the source carries its own
+func (l *imageLoader) part(ctx context.Context, source, mimeType string) (*genai.Part, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case strings.HasPrefix(source, "data:"):
+		data, mimeType, err = l.decodeDataURL(source, mimeType)
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		data, mimeType, err = l.fetch(ctx, source, mimeType)
+	default:
+		data, err = l.decodeBase64(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Fall back to sniffing the bytes when no type was declared
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	mimeType = normalizeMIMEType(mimeType)
+	if !imageMIMETypes[mimeType] {
+		return nil, fmt.Errorf("unsupported image type %q (supported: image/png, image/jpeg, image/webp, image/heic, image/heif)", mimeType)
+	}
+
+	return genai.NewPartFromBytes(data, mimeType), nil
+}
+
+// decodeDataURL decodes a base64 data URL (data:image/png;base64,...)
+// The data URL's own MIME type takes precedence over the declared one
+func (l *imageLoader) decodeDataURL(source, mimeType string) ([]byte, string, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(source, "data:"), ",")
+	if !ok {
+		return nil, "", fmt.Errorf("malformed image data URL")
+	}
+	mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+	if !isBase64 {
+		return nil, "", fmt.Errorf("image data URL must be base64-encoded")
+	}
+	if mediaType != "" {
+		mimeType = mediaType
+	}
+
+	data, err := l.decodeBase64(payload)
+	return data, mimeType, err
+}
+
+// decodeBase64 decodes standard or URL-safe base64, padded or not
+func (l *imageLoader) decodeBase64(payload string) ([]byte, error) {
+	payload = strings.TrimSpace(payload)
+	if int64(base64.StdEncoding.DecodedLen(len(payload))) > l.maxBytes {
+		return nil, fmt.Errorf("image exceeds %d bytes", l.maxBytes)
+	}
+
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := encoding.DecodeString(payload); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("image data is not a data URL, http(s) URL or valid base64")
+}
+
+// fetch downloads an image URL, bounded by the loader's timeout and size limit
+// The response Content-Type is used when no type was declared
+func (l *imageLoader) fetch(ctx context.Context, url, mimeType string) ([]byte, string, error) {
+	if l.client == nil {
+		return nil, "", fmt.Errorf("image URLs are not enabled")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image URL: %w", err)
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch image: %s", resp.Status)
+	}
+	if resp.ContentLength > l.maxBytes {
+		return nil, "", fmt.Errorf("image exceeds %d bytes", l.maxBytes)
+	}
+
+	// Read one byte past the limit to detect oversized bodies without a Content-Length
+	data, err := io.ReadAll(io.LimitReader(resp.Body, l.maxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > l.maxBytes {
+		return nil, "", fmt.Errorf("image exceeds %d bytes", l.maxBytes)
+	}
+
+	if mimeType == "" {
+		mimeType = resp.Header.Get("Content-Type")
+	}
+	return data, mimeType, nil
+}
+
+// normalizeMIMEType lowercases a MIME type and strips parameters (e.g. "; charset=...")
+func normalizeMIMEType(mimeType string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if mimeType == "image/jpg" {
+		return "image/jpeg"
+	}
+	return mimeType
+}
@@ -0,0 +1,79 @@
+package agui_adapter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImageFetchOnlyReachesPublicAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	defer internal.Close()
+	tests := []struct {
+		name         string
+		fetchTimeout time.Duration
+		url          string
+		wantErr      error
+		wantMessage  string
+	}{
+		{"disabled by default", 0, internal.URL, nil, "image URLs are not enabled"},
+		{"loopback address", time.Second, internal.URL, errNonPublicImageURL, ""},
+		{"name resolving internally", time.Second, strings.Replace(internal.URL, "127.0.0.1", "localhost", 1), errNonPublicImageURL, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := newImageLoader(1<<20, tt.fetchTimeout)
+			_, err := loader.part(context.Background(), tt.url, "")
+			if err == nil {
+				t.Fatal("fetched an internal image URL")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantMessage != "" && err.Error() != tt.wantMessage {
+				t.Errorf("err = %q, want %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+// redirectingTransport answers requests to host with a redirect to location, and passes others on
+type redirectingTransport struct {
+	next     http.RoundTripper
+	host     string
+	location string
+}
+
+func (rt redirectingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != rt.host {
+		return rt.next.RoundTrip(req)
+	}
+	return &http.Response{
+		StatusCode: http.StatusFound,
+		Header:     http.Header{"Location": {rt.location}},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func TestImageFetchRefusesRedirectToInternalAddress(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect reached the internal server")
+	}))
+	defer internal.Close()
+
+	loader := newImageLoader(1<<20, time.Second)
+	// Stand in for a public server redirecting to the internal one
+	loader.client.Transport = redirectingTransport{next: loader.client.Transport, host: "images.example", location: internal.URL}
+
+	if _, err := loader.part(context.Background(), "http://images.example/cat.png", ""); !errors.Is(err, errNonPublicImageURL) {
+		t.Errorf("err = %v, want %v", err, errNonPublicImageURL)
+	}
+}
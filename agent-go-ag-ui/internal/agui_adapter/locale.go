@@ -0,0 +1,77 @@
+package agui_adapter
+
+import (
+	"fmt"
+
+	"golang.org/x/text/language"
+)
+
+// ForwardedPropLocale and ForwardedPropLanguage select the response language for a single run
+// Both take a BCP-47 tag; locale wins when both are set
+const (
+	ForwardedPropLocale   = "locale"
+	ForwardedPropLanguage = "language"
+)
+
+// localeResolver picks a supported response language from the request
+type localeResolver struct {
+	supported []string
+	matcher   language.Matcher
+	def       string
+}
+
+// newLocaleResolver creates a resolver for the supported BCP-47 tags
+// Returns nil when no locales are supported, which disables the language instruction
+func newLocaleResolver(supported []string, def string) *localeResolver {
+	if len(supported) == 0 {
+		return nil
+	}
+	tags := make([]language.Tag, 0, len(supported))
+	for _, s := range supported {
+		tags = append(tags, language.Make(s))
+	}
+	return &localeResolver{
+		supported: supported,
+		matcher:   language.NewMatcher(tags),
+		def:       def,
+	}
+}
+
+// resolve returns the supported locale closest to the forwarded props, then the
+// Accept-Language header, falling back to the default when neither matches
+func (l *localeResolver) resolve(forwardedProps map[string]interface{}, acceptLanguage string) string {
+	for _, key := range []string{ForwardedPropLocale, ForwardedPropLanguage} {
+		if requested, ok := forwardedProps[key].(string); ok && requested != "" {
+			if tag, err := language.Parse(requested); err == nil {
+				if locale, ok := l.match(tag); ok {
+					return locale
+				}
+			}
+			return l.def
+		}
+	}
+
+	if acceptLanguage != "" {
+		if tags, _, err := language.ParseAcceptLanguage(acceptLanguage); err == nil && len(tags) > 0 {
+			if locale, ok := l.match(tags...); ok {
+				return locale
+			}
+		}
+	}
+
+	return l.def
+}
+
+// match returns the supported locale that best matches the preferred tags
+func (l *localeResolver) match(preferred ...language.Tag) (string, bool) {
+	_, index, confidence := l.matcher.Match(preferred...)
+	if confidence == language.No {
+		return "", false
+	}
+	return l.supported[index], true
+}
+
+// localeInstruction directs the model to respond in the given language
+func localeInstruction(locale string) string {
+	return fmt.Sprintf("Always respond in the language identified by the BCP-47 tag %q, regardless of the language of the conversation.", locale)
+}
@@ -0,0 +1,32 @@
+package agui_adapter
+
+import "fmt"
+
+// ForwardedPropMessageID sets the ID of the run's assistant message, so a retried run reuses it
+const ForwardedPropMessageID = "messageId"
+
+// validateMessageID checks a client-supplied assistant message ID like thread and run IDs
+func validateMessageID(props map[string]interface{}) error {
+	value, exists := props[ForwardedPropMessageID]
+	if !exists {
+		return nil
+	}
+	messageID, ok := value.(string)
+	if !ok || messageID == "" {
+		return fmt.Errorf("forwardedProps '%s' must be a non-empty string", ForwardedPropMessageID)
+	}
+	return ValidateID(ForwardedPropMessageID, messageID)
+}
+
+// assistantMessageID returns the ID of a run's first assistant message: the client's messageId,
+// else one derived from the client's runId, so retries of a run produce the same IDs;
+// only runs without either get a generated ID
+func assistantMessageID(input *RunAgentInput) string {
+	if messageID, _ := input.ForwardedProps[ForwardedPropMessageID].(string); messageID != "" {
+		return messageID
+	}
+	if input.RunID != "" {
+		return "msg-" + input.RunID
+	}
+	return idGen.GenerateMessageID()
+}
@@ -0,0 +1,81 @@
+package agui_adapter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+// Moderator checks user input before it is sent to the model
+// Flagged input is rejected without calling the model; an error fails the run
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (flagged bool, err error)
+}
+
+// NoopModerator lets all input through
+type NoopModerator struct{}
+
+// Moderate flags nothing
+func (NoopModerator) Moderate(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+// moderatedRoles are the roles of client-supplied messages whose text can reach the model:
+// instructions, user turns and client-side tool results
+// Assistant messages are the model's own output, restored from the session rather than the request
+var moderatedRoles = map[string]bool{
+	"system":    true,
+	"developer": true,
+	"user":      true,
+	"tool":      true,
+}
+
+// moderate runs the text of every client-supplied message through the moderator, earlier turns included,
+// so nothing reaches the model unchecked
+// It reports whether the run was ended, having sent RUN_ERROR
+func (a *AGUIAdapter) moderate(ctx context.Context, input *RunAgentInput, runID string, sender EventSender) (bool, error) {
+	for _, msg := range input.Messages {
+		if role, _ := msg["role"].(string); !moderatedRoles[role] {
+			continue
+		}
+		text := messageText(msg)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		flagged, err := a.moderator.Moderate(ctx, text)
+		if err != nil {
+			return true, sender.SendRunError(runID, fmt.Errorf("input moderation failed: %w", err))
+		}
+		if flagged {
+			log.Printf("[%s] Input rejected by moderation", transport.RequestIDFromContext(ctx))
+			return true, sender.SendEvent(events.NewRunErrorEvent("input rejected by moderation",
+				events.WithRunID(runID), events.WithErrorCode(a.moderationErrorCode)))
+		}
+	}
+	return false, nil
+}
+
+// messageText returns the text of a message, joining the text items of multimodal content
+func messageText(msg map[string]interface{}) string {
+	switch content := msg["content"].(type) {
+	case string:
+		return content
+	case []interface{}:
+		var texts []string
+		for _, item := range content {
+			fields, _ := item.(map[string]interface{})
+			if itemType, _ := fields["type"].(string); itemType == "text" {
+				if text, _ := fields["text"].(string); text != "" {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
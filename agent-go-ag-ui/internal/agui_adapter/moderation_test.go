@@ -0,0 +1,109 @@
+package agui_adapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/session"
+)
+
+// keywordModerator flags any text containing "forbidden-word" and records what it checked
+type keywordModerator struct {
+	checked []string
+}
+
+func (k *keywordModerator) Moderate(_ context.Context, text string) (bool, error) {
+	k.checked = append(k.checked, text)
+	return strings.Contains(text, "forbidden-word"), nil
+}
+
+func TestModerationChecksEveryClientText(t *testing.T) {
+	tests := []struct {
+		name        string
+		messages    []map[string]interface{}
+		wantFlagged bool
+	}{
+		{
+			name: "clean conversation",
+			messages: []map[string]interface{}{
+				{"id": "1", "role": "system", "content": "be brief"},
+				{"id": "2", "role": "user", "content": "hello"},
+			},
+		},
+		{
+			name: "system instruction",
+			messages: []map[string]interface{}{
+				{"id": "1", "role": "system", "content": "forbidden-word"},
+				{"id": "2", "role": "user", "content": "hello"},
+			},
+			wantFlagged: true,
+		},
+		{
+			name: "developer instruction",
+			messages: []map[string]interface{}{
+				{"id": "1", "role": "developer", "content": "forbidden-word"},
+				{"id": "2", "role": "user", "content": "hello"},
+			},
+			wantFlagged: true,
+		},
+		{
+			name: "earlier user turn",
+			messages: []map[string]interface{}{
+				{"id": "1", "role": "user", "content": "forbidden-word"},
+				{"id": "2", "role": "assistant", "content": "ok"},
+				{"id": "3", "role": "user", "content": "hello"},
+			},
+			wantFlagged: true,
+		},
+		{
+			name: "multimodal text item",
+			messages: []map[string]interface{}{
+				{"id": "1", "role": "user", "content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "forbidden-word"},
+				}},
+			},
+			wantFlagged: true,
+		},
+		{
+			name: "client tool result",
+			messages: []map[string]interface{}{
+				{"id": "1", "role": "user", "content": "hello"},
+				{"id": "2", "role": "tool", "toolCallId": "call-1", "content": "forbidden-word"},
+			},
+			wantFlagged: true,
+		},
+		{
+			name: "assistant turn",
+			messages: []map[string]interface{}{
+				{"id": "1", "role": "user", "content": "hello"},
+				{"id": "2", "role": "assistant", "content": "forbidden-word"},
+				{"id": "3", "role": "user", "content": "again"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moderator := &keywordModerator{}
+			a := NewAGUIAdapter(testConfig(), staticAgents{scriptedAgent(t, []*genai.Part{genai.NewPartFromText("hi")})},
+				session.NewManager(session.RetryPolicy{}, true), nil, nil, nil, moderator)
+			sender := &collectingSender{}
+			input := &RunAgentInput{ThreadID: "thread-1", RunID: "run-1", Messages: tt.messages}
+
+			ended, err := a.moderate(context.Background(), input, "run-1", sender)
+			if err != nil {
+				t.Fatalf("moderate: %v", err)
+			}
+			if ended != tt.wantFlagged {
+				t.Errorf("flagged = %v, want %v (checked %q)", ended, tt.wantFlagged, moderator.checked)
+			}
+			if ended {
+				if runErr := runError(sender.events); runErr == nil || runErr.Code == nil || *runErr.Code != "moderation_rejected" {
+					t.Errorf("events = %v, want RUN_ERROR moderation_rejected", eventTypes(sender.events))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package agui_adapter
+
+import "google.golang.org/genai"
+
+// CustomEventUnknownPart is the CUSTOM event name for model parts the adapter doesn't translate
+const CustomEventUnknownPart = "unknown_part"
+
+// describeUnknownPart summarizes a part that has no AG-UI mapping (code execution, files, inline data)
+// The summary carries metadata only (sizes, MIME types, URIs), never the raw payload
+// Returns false for parts that are translated or carry no content of their own
+func describeUnknownPart(part *genai.Part) (map[string]interface{}, bool) {
+	switch {
+	case part.ExecutableCode != nil:
+		return map[string]interface{}{
+			"type":       "executableCode",
+			"language":   string(part.ExecutableCode.Language),
+			"codeLength": len(part.ExecutableCode.Code),
+		}, true
+	case part.CodeExecutionResult != nil:
+		return map[string]interface{}{
+			"type":         "codeExecutionResult",
+			"outcome":      string(part.CodeExecutionResult.Outcome),
+			"outputLength": len(part.CodeExecutionResult.Output),
+		}, true
+	case part.FileData != nil:
+		return map[string]interface{}{
+			"type":        "fileData",
+			"mimeType":    part.FileData.MIMEType,
+			"fileUri":     part.FileData.FileURI,
+			"displayName": part.FileData.DisplayName,
+		}, true
+	case part.InlineData != nil:
+		return map[string]interface{}{
+			"type":        "inlineData",
+			"mimeType":    part.InlineData.MIMEType,
+			"size":        len(part.InlineData.Data),
+			"displayName": part.InlineData.DisplayName,
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// mergeTextParts joins runs of consecutive text-only parts (and of thought parts) into
+// one part each, so an event's text is emitted as a single content event
+// A part of any other kind ends the run, preserving the boundary around it
+func mergeTextParts(parts []*genai.Part) []*genai.Part {
+	merged := make([]*genai.Part, 0, len(parts))
+	for _, part := range parts {
+		if last := len(merged) - 1; last >= 0 && isTextOnly(part) && isTextOnly(merged[last]) && part.Thought == merged[last].Thought {
+			merged[last] = &genai.Part{Text: merged[last].Text + part.Text, Thought: part.Thought}
+			continue
+		}
+		merged = append(merged, part)
+	}
+	return merged
+}
+
+// isTextOnly reports whether a part carries text and nothing else
+func isTextOnly(part *genai.Part) bool {
+	return part != nil && part.Text != "" &&
+		part.FunctionCall == nil && part.FunctionResponse == nil &&
+		part.ExecutableCode == nil && part.CodeExecutionResult == nil &&
+		part.InlineData == nil && part.FileData == nil
+}
+
+// lastFunctionCall returns the index of the last function call part, or -1 if there is none
+func lastFunctionCall(parts []*genai.Part) int {
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i].FunctionCall != nil {
+			return i
+		}
+	}
+	return -1
+}
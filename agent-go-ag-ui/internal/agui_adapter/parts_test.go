@@ -0,0 +1,76 @@
+package agui_adapter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/genai"
+)
+
+// contentDeltas returns the deltas of the run's TEXT_MESSAGE_CONTENT events
+func contentDeltas(evts []events.Event) []string {
+	var deltas []string
+	for _, event := range evts {
+		if e, ok := event.(*SequencedTextMessageContentEvent); ok {
+			deltas = append(deltas, e.Delta)
+		}
+	}
+	return deltas
+}
+
+func TestTwoTextPartsAreOneContentEvent(t *testing.T) {
+	a := newTestAdapter(scriptedAgent(t,
+		[]*genai.Part{genai.NewPartFromText("Hello, "), genai.NewPartFromText("world.")},
+	), nil)
+	evts := runProtocol(t, a, "alice", userInput("thread-1", "hi"))
+
+	if got, want := contentDeltas(evts), []string{"Hello, world."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("content deltas = %q, want %q", got, want)
+	}
+}
+
+func TestMergeTextParts(t *testing.T) {
+	image := &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte{1}}}
+	call := &genai.Part{FunctionCall: &genai.FunctionCall{Name: "get_time"}}
+
+	tests := []struct {
+		name  string
+		parts []*genai.Part
+		want  []*genai.Part
+	}{
+		{
+			name:  "consecutive text",
+			parts: []*genai.Part{{Text: "a"}, {Text: "b"}, {Text: "c"}},
+			want:  []*genai.Part{{Text: "abc"}},
+		},
+		{
+			name:  "non-text part keeps the boundary",
+			parts: []*genai.Part{{Text: "a"}, image, {Text: "b"}},
+			want:  []*genai.Part{{Text: "a"}, image, {Text: "b"}},
+		},
+		{
+			name:  "function call keeps the boundary",
+			parts: []*genai.Part{{Text: "a"}, {Text: "b"}, call, {Text: "c"}},
+			want:  []*genai.Part{{Text: "ab"}, call, {Text: "c"}},
+		},
+		{
+			name:  "thoughts merge apart from text",
+			parts: []*genai.Part{{Text: "x", Thought: true}, {Text: "y", Thought: true}, {Text: "a"}},
+			want:  []*genai.Part{{Text: "xy", Thought: true}, {Text: "a"}},
+		},
+		{
+			name:  "empty text is not merged",
+			parts: []*genai.Part{{Text: "a"}, {}, {Text: "b"}},
+			want:  []*genai.Part{{Text: "a"}, {}, {Text: "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeTextParts(tt.parts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeTextParts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package agui_adapter
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// PostProcessor inspects and optionally rewrites the complete assistant text before it is sent
+// Returning an error aborts the run with RUN_ERROR instead of sending the text
+type PostProcessor interface {
+	Process(ctx context.Context, text string) (string, error)
+}
+
+// PostProcessorChain applies post-processors in order, each seeing the previous one's output
+// The first error stops the chain
+type PostProcessorChain []PostProcessor
+
+// Process runs every post-processor of the chain
+func (c PostProcessorChain) Process(ctx context.Context, text string) (string, error) {
+	for _, postProcessor := range c {
+		var err error
+		if text, err = postProcessor.Process(ctx, text); err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
+
+// WordFilter is a PostProcessor that masks blocked words (case-insensitive, whole words only)
+type WordFilter struct {
+	pattern *regexp.Regexp
+}
+
+// NewWordFilter creates a filter masking the given words; with no words it passes text through
+func NewWordFilter(words []string) *WordFilter {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return &WordFilter{}
+	}
+	return &WordFilter{pattern: regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+// Process replaces each blocked word with asterisks of the same length
+func (f *WordFilter) Process(_ context.Context, text string) (string, error) {
+	if f.pattern == nil {
+		return text, nil
+	}
+	return f.pattern.ReplaceAllStringFunc(text, func(match string) string {
+		return strings.Repeat("*", len([]rune(match)))
+	}), nil
+}
+
+// PatternFilter is a PostProcessor that removes every span matching one of its patterns,
+// e.g. boilerplate disclaimers the model appends
+type PatternFilter struct {
+	patterns []*regexp.Regexp
+}
+
+// NewPatternFilter creates a filter removing matches of the given patterns; with no patterns it passes text through
+func NewPatternFilter(patterns []*regexp.Regexp) *PatternFilter {
+	return &PatternFilter{patterns: patterns}
+}
+
+// Process removes the matching spans, then trims the whitespace they leave at the ends of the text
+func (f *PatternFilter) Process(_ context.Context, text string) (string, error) {
+	stripped := text
+	for _, pattern := range f.patterns {
+		stripped = pattern.ReplaceAllString(stripped, "")
+	}
+	if stripped == text {
+		return text, nil
+	}
+	return strings.TrimSpace(stripped), nil
+}
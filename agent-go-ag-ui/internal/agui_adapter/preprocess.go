@@ -0,0 +1,46 @@
+package agui_adapter
+
+import (
+	"context"
+	"fmt"
+)
+
+// RequestMiddleware inspects and optionally rewrites a validated request before the agent runs
+// (e.g. PII scrubbing, prompt templating); changes are made in place on input
+// Returning an error aborts the run with RUN_ERROR before the model is called
+type RequestMiddleware interface {
+	Process(ctx context.Context, input *RunAgentInput) error
+}
+
+// RequestMiddlewareChain applies middlewares in order, each seeing the previous one's changes
+// The first error stops the chain
+type RequestMiddlewareChain []RequestMiddleware
+
+// Process runs every middleware of the chain
+func (c RequestMiddlewareChain) Process(ctx context.Context, input *RunAgentInput) error {
+	for _, middleware := range c {
+		if err := middleware.Process(ctx, input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NoopRequestMiddleware leaves requests unchanged
+type NoopRequestMiddleware struct{}
+
+// Process does nothing
+func (NoopRequestMiddleware) Process(context.Context, *RunAgentInput) error {
+	return nil
+}
+
+// preprocess runs the request middleware and re-validates the messages it may have rewritten
+func (a *AGUIAdapter) preprocess(ctx context.Context, input *RunAgentInput) error {
+	if err := a.preprocessor.Process(ctx, input); err != nil {
+		return fmt.Errorf("request preprocessing failed: %w", err)
+	}
+	if err := ValidateMessages(input.Messages); err != nil {
+		return fmt.Errorf("request preprocessing produced invalid messages: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package agui_adapter
+
+import (
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/genai"
+)
+
+const (
+	// CustomEventSearching is sent when a search tool call starts, with its query
+	CustomEventSearching = "searching"
+	// CustomEventSearchComplete is sent when a search tool call's result arrives
+	CustomEventSearchComplete = "search_complete"
+)
+
+// toolProgress names the CUSTOM events that show a tool's progress in the UI
+type toolProgress struct {
+	// started is sent when a call starts, completed when its result arrives
+	started   string
+	completed string
+	// queryArg is the call argument shown with the started event ("" = none)
+	queryArg string
+}
+
+// progressTools lists the tools whose calls are announced with progress events, by function name
+// Add an entry to give another slow tool a live indicator
+var progressTools = map[string]toolProgress{
+	"google_search": {started: CustomEventSearching, completed: CustomEventSearchComplete, queryArg: "query"},
+}
+
+// startProgress announces a call to a progress tool, with its query if the arguments carry one
+func (t *runTranslation) startProgress(toolCallID, name string, args map[string]any) {
+	progress, ok := progressTools[name]
+	if !ok {
+		return
+	}
+	value := map[string]interface{}{"toolCallId": toolCallID}
+	if query, ok := args[progress.queryArg].(string); ok && query != "" {
+		value["query"] = query
+	}
+	t.eventChan <- events.NewCustomEvent(progress.started, events.WithValue(value))
+}
+
+// completeProgress announces the result of a call to a progress tool
+func (t *runTranslation) completeProgress(toolCallID, name string) {
+	progress, ok := progressTools[name]
+	if !ok {
+		return
+	}
+	t.eventChan <- events.NewCustomEvent(progress.completed, events.WithValue(map[string]interface{}{
+		"toolCallId": toolCallID,
+	}))
+}
+
+// searchQueries returns the queries of a search the model ran itself (the built-in GoogleSearch),
+// which is only reported along with the response it grounded
+func searchQueries(metadata *genai.GroundingMetadata) []string {
+	if metadata == nil {
+		return nil
+	}
+	return metadata.WebSearchQueries
+}
@@ -0,0 +1,55 @@
+package agui_adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+const (
+	// ForwardedPropResponseFormat selects the response format of a single run: "text" (default) or "json"
+	ForwardedPropResponseFormat = "responseFormat"
+	// ForwardedPropResponseSchema is an optional JSON Schema for "json" responses
+	ForwardedPropResponseSchema = "responseSchema"
+)
+
+// errInvalidJSONResponse ends JSON mode runs whose response doesn't parse
+var errInvalidJSONResponse = errors.New("response is not valid JSON")
+
+// validateResponseFormat checks the per-request response format and its schema
+func validateResponseFormat(props map[string]interface{}) error {
+	format := "text"
+	if value, exists := props[ForwardedPropResponseFormat]; exists {
+		name, ok := value.(string)
+		if !ok || (name != "text" && name != "json") {
+			return fmt.Errorf("forwardedProps '%s' must be \"text\" or \"json\"", ForwardedPropResponseFormat)
+		}
+		format = name
+	}
+	if value, exists := props[ForwardedPropResponseSchema]; exists {
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Errorf("forwardedProps '%s' must be an object", ForwardedPropResponseSchema)
+		}
+		if format != "json" {
+			return fmt.Errorf("forwardedProps '%s' requires '%s' \"json\"", ForwardedPropResponseSchema, ForwardedPropResponseFormat)
+		}
+	}
+	return nil
+}
+
+// responseFormatFromProps returns the per-request response format
+func responseFormatFromProps(props map[string]interface{}) transport.ResponseFormat {
+	if format, _ := props[ForwardedPropResponseFormat].(string); format != "json" {
+		return transport.ResponseFormat{}
+	}
+	schema, _ := props[ForwardedPropResponseSchema].(map[string]interface{})
+	return transport.ResponseFormat{JSON: true, Schema: schema}
+}
+
+// isJSONResponse reports whether the complete response text is a single JSON value
+func isJSONResponse(text string) bool {
+	return strings.TrimSpace(text) != "" && json.Valid([]byte(text))
+}
@@ -0,0 +1,20 @@
+package agui_adapter
+
+import (
+	"time"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// CustomEventRetrying is the CUSTOM event name sent before a failed model call is retried
+const CustomEventRetrying = "retrying"
+
+// retryingEvent announces the upcoming model attempt and the delay before it starts
+// Retries only happen before the run produced any content, so the client can't see duplicates
+func retryingEvent(attempt, maxAttempts int, delay time.Duration) events.Event {
+	return events.NewCustomEvent(CustomEventRetrying, events.WithValue(map[string]interface{}{
+		"attempt":     attempt,
+		"maxAttempts": maxAttempts,
+		"delayMs":     delay.Milliseconds(),
+	}))
+}
@@ -0,0 +1,160 @@
+package agui_adapter
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/adk/agent"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/config"
+	"agent-go-ag-ui/internal/transport"
+)
+
+// failingAgent yields one partial model event per step, then fails with err
+func failingAgent(t *testing.T, err error, steps ...[]*genai.Part) agent.Agent {
+	t.Helper()
+	a, agentErr := agent.New(agent.Config{
+		Name: "failing_agent",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(yield func(*adksession.Event, error) bool) {
+				for _, parts := range steps {
+					event := adksession.NewEvent(ctx.InvocationID())
+					event.Author = "failing_agent"
+					event.Content = genai.NewContentFromParts(parts, genai.RoleModel)
+					event.Partial = true
+					if !yield(event, nil) {
+						return
+					}
+				}
+				yield(nil, err)
+			}
+		},
+	})
+	if agentErr != nil {
+		t.Fatalf("agent.New: %v", agentErr)
+	}
+	return a
+}
+
+func TestRunErrorClosesOpenToolCalls(t *testing.T) {
+	a := newTestAdapter(failingAgent(t, errors.New("model unavailable"),
+		[]*genai.Part{genai.NewPartFromText("Let me check.")},
+		[]*genai.Part{{FunctionCall: &genai.FunctionCall{ID: "call-1", Name: "get_time", Args: map[string]any{}}}},
+	), nil)
+	evts := withoutType(runProtocol(t, a, "alice", userInput("thread-1", "what time is it?")), events.EventTypeCustom)
+
+	want := []events.EventType{
+		events.EventTypeRunStarted,
+		events.EventTypeTextMessageStart,
+		events.EventTypeTextMessageContent,
+		events.EventTypeTextMessageEnd,
+		events.EventTypeToolCallStart,
+		events.EventTypeToolCallArgs,
+		events.EventTypeToolCallEnd,
+		events.EventTypeRunError,
+	}
+	if got := eventTypes(evts); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if end := evts[6].(*events.ToolCallEndEvent); end.ToolCallID != "call-1" {
+		t.Errorf("TOOL_CALL_END for %q, want call-1", end.ToolCallID)
+	}
+}
+
+func TestOversizedStateFailsBeforeRunStarts(t *testing.T) {
+	a := newTestAdapter(scriptedAgent(t, []*genai.Part{genai.NewPartFromText("Hi")}), nil)
+	stateMgr := transport.NewStateManager(32)
+	input := userInput("thread-1", "hello")
+	input.State = map[string]interface{}{"notes": strings.Repeat("x", 64)}
+	sender := &collectingSender{}
+
+	if err := a.RunAgentProtocol(transport.WithPrincipal(context.Background(), "alice"), input, stateMgr, sender); err != nil {
+		t.Fatalf("RunAgentProtocol: %v", err)
+	}
+
+	want := []events.EventType{events.EventTypeRunError}
+	if got := eventTypes(sender.events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if msg := runError(sender.events).Message; !strings.Contains(msg, transport.ErrStateTooLarge.Error()) {
+		t.Errorf("RUN_ERROR = %q, want %q", msg, transport.ErrStateTooLarge)
+	}
+	if _, ok := stateMgr.Lookup("thread-1"); ok {
+		t.Error("oversized state was stored")
+	}
+}
+
+// openMessagesAt returns the IDs of the text messages still open when the first RUN_ERROR is sent
+func openMessagesAt(evts []events.Event) []string {
+	open := map[string]bool{}
+	for _, event := range evts {
+		switch e := event.(type) {
+		case *events.TextMessageStartEvent:
+			open[e.MessageID] = true
+		case *events.TextMessageEndEvent:
+			delete(open, e.MessageID)
+		case *events.RunErrorEvent:
+			var ids []string
+			for id := range open {
+				ids = append(ids, id)
+			}
+			return ids
+		}
+	}
+	return nil
+}
+
+func TestRunnerFailureLeavesNoOpenMessage(t *testing.T) {
+	// A sub-agent named like its root makes runner.New reject the agent tree
+	sub, err := agent.New(agent.Config{Name: "root_agent"})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	root, err := agent.New(agent.Config{Name: "root_agent", SubAgents: []agent.Agent{sub}})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	a := newTestAdapter(root, func(cfg *config.Config) {
+		cfg.EmitTyping = true
+	})
+	evts := withoutType(runProtocol(t, a, "alice", userInput("thread-1", "hello")), events.EventTypeCustom)
+
+	want := []events.EventType{events.EventTypeRunStarted, events.EventTypeRunError}
+	if got := eventTypes(evts); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if msg := runError(evts).Message; !strings.Contains(msg, "failed to create runner") {
+		t.Errorf("RUN_ERROR = %q, want a runner failure", msg)
+	}
+	if open := openMessagesAt(evts); len(open) > 0 {
+		t.Errorf("messages %v still open at RUN_ERROR", open)
+	}
+}
+
+func TestAgentFailureEndsTextMessageBeforeRunError(t *testing.T) {
+	a := newTestAdapter(failingAgent(t, errors.New("model unavailable"),
+		[]*genai.Part{genai.NewPartFromText("Half an ans")},
+	), nil)
+	evts := withoutType(runProtocol(t, a, "alice", userInput("thread-1", "hello")), events.EventTypeCustom)
+
+	want := []events.EventType{
+		events.EventTypeRunStarted,
+		events.EventTypeTextMessageStart,
+		events.EventTypeTextMessageContent,
+		events.EventTypeTextMessageEnd,
+		events.EventTypeRunError,
+	}
+	if got := eventTypes(evts); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if open := openMessagesAt(evts); len(open) > 0 {
+		t.Errorf("messages %v still open at RUN_ERROR", open)
+	}
+}
@@ -0,0 +1,90 @@
+package agui_adapter
+
+import (
+	"fmt"
+
+	"agent-go-ag-ui/internal/config"
+	"agent-go-ag-ui/internal/transport"
+)
+
+const (
+	// ForwardedPropTemperature overrides DEFAULT_TEMPERATURE for a single run
+	ForwardedPropTemperature = "temperature"
+	// ForwardedPropTopP overrides DEFAULT_TOP_P for a single run
+	ForwardedPropTopP = "topP"
+	// ForwardedPropStopSequences overrides STOP_SEQUENCES for a single run
+	ForwardedPropStopSequences = "stopSequences"
+)
+
+// validateSampling checks the per-request sampling overrides are numbers in range,
+// and the stop sequences are strings within the model's limit
+func validateSampling(props map[string]interface{}) error {
+	checks := []struct {
+		key      string
+		validate func(float64) error
+	}{
+		{ForwardedPropTemperature, config.ValidateTemperature},
+		{ForwardedPropTopP, config.ValidateTopP},
+	}
+	for _, check := range checks {
+		value, exists := props[check.key]
+		if !exists {
+			continue
+		}
+		number, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("forwardedProps '%s' must be a number", check.key)
+		}
+		if err := check.validate(number); err != nil {
+			return fmt.Errorf("forwardedProps '%s': %w", check.key, err)
+		}
+	}
+
+	if value, exists := props[ForwardedPropStopSequences]; exists {
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("forwardedProps '%s' must be an array of strings", ForwardedPropStopSequences)
+		}
+		sequences := make([]string, 0, len(items))
+		for _, item := range items {
+			sequence, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("forwardedProps '%s' must be an array of strings", ForwardedPropStopSequences)
+			}
+			sequences = append(sequences, sequence)
+		}
+		if err := config.ValidateStopSequences(sequences); err != nil {
+			return fmt.Errorf("forwardedProps '%s': %w", ForwardedPropStopSequences, err)
+		}
+	}
+	return nil
+}
+
+// stopSequencesFromProps returns the per-request stop sequences, or nil if none are set
+func stopSequencesFromProps(props map[string]interface{}) []string {
+	items, ok := props[ForwardedPropStopSequences].([]interface{})
+	if !ok {
+		return nil
+	}
+	sequences := make([]string, 0, len(items))
+	for _, item := range items {
+		if sequence, ok := item.(string); ok {
+			sequences = append(sequences, sequence)
+		}
+	}
+	return sequences
+}
+
+// samplingFromProps returns the per-request sampling overrides
+func samplingFromProps(props map[string]interface{}) transport.Sampling {
+	var sampling transport.Sampling
+	if temperature, ok := props[ForwardedPropTemperature].(float64); ok {
+		value := float32(temperature)
+		sampling.Temperature = &value
+	}
+	if topP, ok := props[ForwardedPropTopP].(float64); ok {
+		value := float32(topP)
+		sampling.TopP = &value
+	}
+	return sampling
+}
@@ -0,0 +1,29 @@
+package agui_adapter
+
+import (
+	"encoding/json"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// SequencedTextMessageContentEvent is a TEXT_MESSAGE_CONTENT event carrying the position of
+// its delta within the message, so clients can detect gaps and duplicates
+// Sequence starts at 0 for each messageId
+type SequencedTextMessageContentEvent struct {
+	*events.TextMessageContentEvent
+	Sequence int `json:"sequence"`
+}
+
+// newSequencedTextMessageContentEvent creates a content event for the delta at position sequence
+func newSequencedTextMessageContentEvent(messageID, delta string, sequence int) *SequencedTextMessageContentEvent {
+	return &SequencedTextMessageContentEvent{
+		TextMessageContentEvent: events.NewTextMessageContentEvent(messageID, delta),
+		Sequence:                sequence,
+	}
+}
+
+// ToJSON serializes the event including its sequence number
+// The embedded event's ToJSON would drop it
+func (e *SequencedTextMessageContentEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
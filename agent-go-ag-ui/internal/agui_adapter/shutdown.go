@@ -0,0 +1,36 @@
+package agui_adapter
+
+import (
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// CustomEventServerShutdown tells a client its run was interrupted because the server is stopping,
+// so it can show a reconnect message; it is followed by RUN_ERROR
+const CustomEventServerShutdown = "server_shutdown"
+
+// ErrorCodeServerShutdown is the RUN_ERROR code of runs interrupted by a shutdown
+const ErrorCodeServerShutdown = "server_shutdown"
+
+// serverShutdownMessage is the RUN_ERROR message of runs interrupted by a shutdown
+const serverShutdownMessage = "server is shutting down, please reconnect"
+
+// Shutdown interrupts the runs still in progress: each is cancelled, closes what it has open,
+// and ends with a server_shutdown event and RUN_ERROR instead of RUN_FINISHED
+// Safe to call more than once
+func (a *AGUIAdapter) Shutdown() {
+	a.shutdownOnce.Do(func() {
+		close(a.shutdown)
+	})
+}
+
+// sendShutdown tells the client its run was interrupted by a shutdown
+func sendShutdown(runID string, sender EventSender) error {
+	notice := events.NewCustomEvent(CustomEventServerShutdown, events.WithValue(map[string]interface{}{
+		"message": serverShutdownMessage,
+	}))
+	if err := sender.SendEvent(notice); err != nil {
+		return err
+	}
+	return sender.SendEvent(events.NewRunErrorEvent(serverShutdownMessage,
+		events.WithRunID(runID), events.WithErrorCode(ErrorCodeServerShutdown)))
+}
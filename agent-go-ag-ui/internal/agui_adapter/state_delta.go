@@ -0,0 +1,105 @@
+package agui_adapter
+
+import (
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+// removeOps builds JSON Patch remove operations for top-level state keys
+func removeOps(keys []string) []events.JSONPatchOperation {
+	ops := make([]events.JSONPatchOperation, 0, len(keys))
+	for _, key := range keys {
+		ops = append(ops, events.JSONPatchOperation{
+			Op:   "remove",
+			Path: jsonPointer(key),
+		})
+	}
+	return ops
+}
+
+// jsonPointer returns the RFC 6901 JSON Pointer for a top-level key
+func jsonPointer(key string) string {
+	return "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// toolStateUpdate turns the state changes of a run's tools into a StateManager.Merge input:
+// a key a tool set to nil is removed from the thread state
+func toolStateUpdate(changes map[string]interface{}) map[string]interface{} {
+	update := make(map[string]interface{}, len(changes))
+	var reset []interface{}
+	for k, v := range changes {
+		if v == nil {
+			reset = append(reset, k)
+			continue
+		}
+		update[k] = v
+	}
+	if len(reset) > 0 {
+		update[transport.StateResetKey] = reset
+	}
+	return update
+}
+
+// emitStateDelta sends a STATE_DELTA taking the client from the state it last saw to that state
+// with the changes the run's tools made so far; nothing is sent if nothing changed
+func (t *runTranslation) emitStateDelta(changes map[string]interface{}) {
+	current := make(map[string]interface{}, len(t.state)+len(changes))
+	for k, v := range t.state {
+		current[k] = v
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(current, k)
+			continue
+		}
+		current[k] = v
+	}
+	current, err := transport.NormalizeState(current)
+	if err != nil {
+		log.Printf("Skipping STATE_DELTA: %v", err)
+		return
+	}
+
+	ops := stateDiffOps(t.state, current)
+	if len(ops) == 0 {
+		return
+	}
+	t.eventChan <- events.NewStateDeltaEvent(ops)
+	t.state = current
+}
+
+// stateDiffOps builds the JSON Patch operations turning from into to, per top-level key:
+// remove for keys only in from, add for keys only in to, replace for changed values
+func stateDiffOps(from, to map[string]interface{}) []events.JSONPatchOperation {
+	var ops []events.JSONPatchOperation
+	var removed []string
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	ops = append(ops, removeOps(removed)...)
+
+	keys := make([]string, 0, len(to))
+	for key := range to {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		old, existed := from[key]
+		switch {
+		case !existed:
+			ops = append(ops, events.JSONPatchOperation{Op: "add", Path: jsonPointer(key), Value: to[key]})
+		case !reflect.DeepEqual(old, to[key]):
+			ops = append(ops, events.JSONPatchOperation{Op: "replace", Path: jsonPointer(key), Value: to[key]})
+		}
+	}
+	return ops
+}
@@ -0,0 +1,32 @@
+package agui_adapter
+
+import (
+	"fmt"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// ForwardedPropStateSyncOnly merges and returns the thread state without running the model,
+// even when messages are present
+const ForwardedPropStateSyncOnly = "stateSyncOnly"
+
+// StateSyncOnly reports whether the request only syncs state
+func (r *RunAgentInput) StateSyncOnly() bool {
+	syncOnly, _ := r.ForwardedProps[ForwardedPropStateSyncOnly].(bool)
+	return syncOnly
+}
+
+// sendStateSync answers a state-only request that carries messages as a run without model output
+func sendStateSync(threadID, runID string, state map[string]interface{}, sender EventSender) error {
+	syncEvents := []events.Event{
+		events.NewRunStartedEvent(threadID, runID),
+		events.NewStateSnapshotEvent(state),
+		events.NewRunFinishedEvent(threadID, runID),
+	}
+	for _, event := range syncEvents {
+		if err := sender.SendEvent(event); err != nil {
+			return fmt.Errorf("failed to send state sync: %w", err)
+		}
+	}
+	return nil
+}
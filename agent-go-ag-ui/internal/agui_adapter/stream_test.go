@@ -0,0 +1,121 @@
+package agui_adapter
+
+import (
+	"iter"
+	"testing"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/adk/agent"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/config"
+)
+
+// finalResponsesAgent yields each text as a complete (non-partial) model response,
+// like a flow that answers, then carries on and answers again
+func finalResponsesAgent(t *testing.T, texts ...string) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "final_responses_agent",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(yield func(*adksession.Event, error) bool) {
+				for _, text := range texts {
+					event := adksession.NewEvent(ctx.InvocationID())
+					event.Author = "final_responses_agent"
+					event.Content = genai.NewContentFromText(text, genai.RoleModel)
+					if !yield(event, nil) {
+						return
+					}
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+func TestConsumeAfterFinalResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		consume bool
+		want    string
+	}{
+		{name: "stop at the first final response", consume: false, want: "First. "},
+		{name: "consume until the stream ends", consume: true, want: "First. Second."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAdapter(finalResponsesAgent(t, "First. ", "Second."), func(cfg *config.Config) {
+				cfg.ConsumeAfterFinalResponse = tt.consume
+			})
+			evts := runProtocol(t, a, "alice", userInput("thread-1", "hello"))
+
+			if got := assistantText(evts); got != tt.want {
+				t.Errorf("assistant text = %q, want %q", got, tt.want)
+			}
+			if runErr := runError(evts); runErr != nil {
+				t.Errorf("unexpected RUN_ERROR: %s", runErr.Message)
+			}
+		})
+	}
+}
+
+func TestEmptyModelStream(t *testing.T) {
+	tests := []struct {
+		name          string
+		emptyResponse string
+		agent         func(t *testing.T) agent.Agent
+		wantError     bool
+		wantFallback  bool
+	}{
+		{
+			name:          "fallback",
+			emptyResponse: "fallback",
+			agent:         func(t *testing.T) agent.Agent { return finalResponsesAgent(t) },
+			wantFallback:  true,
+		},
+		{
+			name:          "error",
+			emptyResponse: "error",
+			agent:         func(t *testing.T) agent.Agent { return finalResponsesAgent(t) },
+			wantError:     true,
+		},
+		{
+			name:          "error mode with only tool calls",
+			emptyResponse: "error",
+			agent:         func(t *testing.T) agent.Agent { return scriptedAgent(t, toolCallSteps()[:2]...) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAdapter(tt.agent(t), func(cfg *config.Config) {
+				cfg.EmptyResponse = tt.emptyResponse
+			})
+			evts := runProtocol(t, a, "alice", userInput("thread-1", "hello"))
+
+			runErr := runError(evts)
+			if tt.wantError {
+				if runErr == nil || runErr.Message != errEmptyModelResponse.Error() {
+					t.Fatalf("RUN_ERROR = %v, want %q", runErr, errEmptyModelResponse)
+				}
+			} else if runErr != nil {
+				t.Fatalf("unexpected RUN_ERROR: %s", runErr.Message)
+			}
+			if text := assistantText(evts); (text == defaultResponseText) != tt.wantFallback {
+				t.Errorf("assistant text = %q, want fallback %v", text, tt.wantFallback)
+			}
+			wantLast := events.EventTypeRunFinished
+			if tt.wantError {
+				wantLast = events.EventTypeRunError
+			}
+			if last := evts[len(evts)-1].Type(); last != wantLast {
+				t.Errorf("last event = %s, want %s", last, wantLast)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package agui_adapter
+
+import (
+	"encoding/json"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// StructuredToolCallArgsEvent is a TOOL_CALL_ARGS event that also carries the arguments as an
+// object, so clients don't have to parse the JSON string delta
+type StructuredToolCallArgsEvent struct {
+	*events.ToolCallArgsEvent
+	Args map[string]any `json:"args"`
+}
+
+// ToJSON serializes the event including its arguments object
+// The embedded event's ToJSON would drop it
+func (e *StructuredToolCallArgsEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// StructuredToolCallResultEvent is a TOOL_CALL_RESULT event that also carries the result as an
+// object, so clients don't have to parse the JSON string content
+type StructuredToolCallResultEvent struct {
+	*events.ToolCallResultEvent
+	Result map[string]any `json:"result"`
+}
+
+// ToJSON serializes the event including its result object
+// The embedded event's ToJSON would drop it
+func (e *StructuredToolCallResultEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
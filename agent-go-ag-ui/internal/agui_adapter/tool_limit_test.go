@@ -0,0 +1,113 @@
+package agui_adapter
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/adk/agent"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/config"
+)
+
+// maxLoopRounds bounds loopingAgent, so a missing limit fails the test instead of hanging it
+const maxLoopRounds = 100
+
+// loopingAgent calls a tool and gets its result, over and over, like a model stuck in a loop
+// rounds counts the tool calls it made
+func loopingAgent(t *testing.T, rounds *atomic.Int32) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "looping_agent",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(yield func(*adksession.Event, error) bool) {
+				for i := range maxLoopRounds {
+					rounds.Add(1)
+					id := fmt.Sprintf("call-%d", i)
+					call := adksession.NewEvent(ctx.InvocationID())
+					call.Author = "looping_agent"
+					call.Content = genai.NewContentFromParts([]*genai.Part{
+						{FunctionCall: &genai.FunctionCall{ID: id, Name: "get_time", Args: map[string]any{}}},
+					}, genai.RoleModel)
+					if !yield(call, nil) {
+						return
+					}
+					response := adksession.NewEvent(ctx.InvocationID())
+					response.Author = "looping_agent"
+					response.Content = genai.NewContentFromParts([]*genai.Part{
+						{FunctionResponse: &genai.FunctionResponse{ID: id, Name: "get_time", Response: map[string]any{"time": "12:00"}}},
+					}, genai.RoleUser)
+					if !yield(response, nil) {
+						return
+					}
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+func TestMaxToolCallsStopsLoopingAgent(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxToolCalls int
+		wantCalls    int
+		wantError    bool
+	}{
+		{name: "unlimited", maxToolCalls: 0, wantCalls: maxLoopRounds},
+		{name: "limit 1", maxToolCalls: 1, wantCalls: 1, wantError: true},
+		{name: "limit 3", maxToolCalls: 3, wantCalls: 3, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rounds atomic.Int32
+			a := newTestAdapter(loopingAgent(t, &rounds), func(cfg *config.Config) {
+				cfg.MaxToolCalls = tt.maxToolCalls
+			})
+			evts := runProtocol(t, a, "alice", userInput("thread-1", "what time is it?"))
+
+			starts, ends := 0, 0
+			for _, event := range evts {
+				switch event.Type() {
+				case events.EventTypeToolCallStart:
+					starts++
+				case events.EventTypeToolCallEnd:
+					ends++
+				}
+			}
+			if starts != tt.wantCalls {
+				t.Errorf("TOOL_CALL_START count = %d, want %d", starts, tt.wantCalls)
+			}
+			if ends != starts {
+				t.Errorf("TOOL_CALL_END count = %d, want one per started call (%d)", ends, starts)
+			}
+
+			runErr := runError(evts)
+			if !tt.wantError {
+				if runErr != nil {
+					t.Fatalf("unexpected RUN_ERROR: %s", runErr.Message)
+				}
+				return
+			}
+			if runErr == nil || !strings.Contains(runErr.Message, errToolCallLimit.Error()) {
+				t.Fatalf("RUN_ERROR = %v, want %q", runErr, errToolCallLimit)
+			}
+			if last := evts[len(evts)-1]; last.Type() != events.EventTypeRunError {
+				t.Errorf("last event = %s, want RUN_ERROR", last.Type())
+			}
+			// The agent is stopped on the call over the limit, not left looping
+			if got := int(rounds.Load()); got != tt.maxToolCalls+1 {
+				t.Errorf("agent made %d tool calls, want %d", got, tt.maxToolCalls+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package agui_adapter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/genai"
+
+	"agent-go-ag-ui/internal/config"
+)
+
+// toolCallSteps is a run that calls get_time, gets its result and answers
+func toolCallSteps() [][]*genai.Part {
+	return [][]*genai.Part{
+		{{FunctionCall: &genai.FunctionCall{ID: "call-1", Name: "get_time", Args: map[string]any{}}}},
+		{{FunctionResponse: &genai.FunctionResponse{ID: "call-1", Name: "get_time", Response: map[string]any{"time": "12:00"}}}},
+		{genai.NewPartFromText("It is noon.")},
+	}
+}
+
+// toolResults returns the TOOL_CALL_RESULT events of a run
+func toolResults(evts []events.Event) []*events.ToolCallResultEvent {
+	var results []*events.ToolCallResultEvent
+	for _, event := range evts {
+		if e, ok := event.(*events.ToolCallResultEvent); ok {
+			results = append(results, e)
+		}
+	}
+	return results
+}
+
+func TestToolResultMessageIDIsDeterministic(t *testing.T) {
+	var ids []string
+	for range 2 {
+		a := newTestAdapter(scriptedAgent(t, toolCallSteps()...), nil)
+		results := toolResults(runProtocol(t, a, "alice", userInput("thread-1", "what time is it?")))
+		if len(results) != 1 {
+			t.Fatalf("got %d tool results, want 1", len(results))
+		}
+		ids = append(ids, results[0].MessageID)
+	}
+
+	if want := "msg-run-1-tool-call-1"; ids[0] != want {
+		t.Errorf("tool result messageId = %q, want %q", ids[0], want)
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("retried run changed the tool result messageId: %q, then %q", ids[0], ids[1])
+	}
+}
+
+func TestToolsOnlyRunHasNoFallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		steps        [][]*genai.Part
+		wantFallback bool
+	}{
+		{name: "tool call and result", steps: toolCallSteps()[:2]},
+		{name: "tool call, result and text", steps: toolCallSteps()},
+		{name: "nothing", steps: [][]*genai.Part{{genai.NewPartFromText("")}}, wantFallback: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAdapter(scriptedAgent(t, tt.steps...), nil)
+			evts := runProtocol(t, a, "alice", userInput("thread-1", "what time is it?"))
+
+			text := assistantText(evts)
+			if gotFallback := strings.Contains(text, defaultResponseText); gotFallback != tt.wantFallback {
+				t.Errorf("assistant text = %q, want fallback %v", text, tt.wantFallback)
+			}
+			if last := evts[len(evts)-1]; last.Type() != events.EventTypeRunFinished {
+				t.Errorf("last event = %s, want RUN_FINISHED", last.Type())
+			}
+		})
+	}
+}
+
+func TestToolResultMessageMode(t *testing.T) {
+	afterText := append([][]*genai.Part{{genai.NewPartFromText("Let me check.")}}, toolCallSteps()...)
+
+	tests := []struct {
+		name  string
+		mode  string
+		steps [][]*genai.Part
+		want  string
+	}{
+		{name: "separate", mode: "separate", steps: toolCallSteps(), want: "msg-run-1-tool-call-1"},
+		{name: "separate after text", mode: "separate", steps: afterText, want: "msg-run-1-tool-call-1"},
+		// Without text before the call, the result belongs to the message that answers after it
+		{name: "assistant", mode: "assistant", steps: toolCallSteps(), want: "msg-run-1"},
+		// After text, the result belongs to the message the call interrupted
+		{name: "assistant after text", mode: "assistant", steps: afterText, want: "msg-run-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAdapter(scriptedAgent(t, tt.steps...), func(cfg *config.Config) {
+				cfg.ToolResultMessageMode = tt.mode
+			})
+			evts := runProtocol(t, a, "alice", userInput("thread-1", "what time is it?"))
+
+			results := toolResults(evts)
+			if len(results) != 1 {
+				t.Fatalf("got %d tool results, want 1", len(results))
+			}
+			if got := results[0].MessageID; got != tt.want {
+				t.Errorf("tool result messageId = %q, want %q", got, tt.want)
+			}
+
+			// Only the assistant mode threads the result into an assistant message of the run
+			assistantMessage := false
+			for _, event := range evts {
+				if e, ok := event.(*events.TextMessageStartEvent); ok && e.MessageID == results[0].MessageID {
+					assistantMessage = true
+				}
+			}
+			if assistantMessage != (tt.mode == "assistant") {
+				t.Errorf("result in an assistant message = %v, want %v", assistantMessage, tt.mode == "assistant")
+			}
+		})
+	}
+}
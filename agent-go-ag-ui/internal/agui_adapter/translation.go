@@ -0,0 +1,303 @@
+package agui_adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// CustomEventToolCallError reports a tool call that ended without a result, e.g. because the run was cancelled
+const CustomEventToolCallError = "tool_call_error"
+
+// CustomEventPlan announces that the following text message is the model's plan before a tool call,
+// so UIs can collapse it
+const CustomEventPlan = "plan"
+
+// CustomEventTyping shows a typing indicator from the model call until the first output arrives
+const CustomEventTyping = "typing"
+
+// runTranslation tracks the per-run state of the ADK → AG-UI conversion
+// It owns the message lifecycle so segments are always opened and closed in order:
+// a thinking segment is closed before the assistant TEXT_MESSAGE is opened
+type runTranslation struct {
+	messageID        string
+	role             string
+	eventChan        chan<- events.Event
+	responseBuilder  strings.Builder
+	toolCallMap      map[string]string
+	startedToolCalls map[string]bool
+	// unidentifiedToolCalls queues, per function name, the AG-UI IDs of calls the model made without an ID
+	unidentifiedToolCalls map[string][]string
+	messageStarted        bool
+	thinking              bool
+	// textSequence numbers the content deltas of the assistant message
+	textSequence int
+	// toolCalls counts the tool calls started in this run
+	toolCalls int
+	// toolResultsEmitted records that at least one TOOL_CALL_RESULT was sent
+	toolResultsEmitted bool
+	// buffered holds assistant text back until release, for post-processing
+	buffered bool
+	// sentences, when set, releases streamed text at sentence boundaries instead of per chunk
+	sentences *sentenceChunker
+	// typing is set while a typing indicator is shown
+	typing bool
+	// planning is set while the open message is a plan segment
+	planning bool
+	// baseMessageID is the run's first message ID; later messages are numbered after it
+	baseMessageID string
+	// messages counts the messages closed so far
+	messages int
+	// toolCallMessages maps each started tool call to the assistant message it belongs to
+	toolCallMessages map[string]string
+	// state is the thread state as the client last saw it, for STATE_DELTA events
+	state map[string]interface{}
+}
+
+// newRunTranslation creates the translation state for a run
+func newRunTranslation(messageID, role string, eventChan chan<- events.Event, buffered, chunkSentences bool) *runTranslation {
+	var sentences *sentenceChunker
+	if chunkSentences {
+		sentences = &sentenceChunker{}
+	}
+	return &runTranslation{
+		sentences:        sentences,
+		messageID:        messageID,
+		baseMessageID:    messageID,
+		role:             role,
+		eventChan:        eventChan,
+		buffered:         buffered,
+		toolCallMap:      make(map[string]string),
+		startedToolCalls: make(map[string]bool),
+
+		unidentifiedToolCalls: make(map[string][]string),
+		toolCallMessages:      make(map[string]string),
+	}
+}
+
+// callToolCallID assigns the AG-UI ID of a function call
+// Calls without an ID get a generated one, queued under the function name for their response
+func (t *runTranslation) callToolCallID(id, name string) string {
+	if id == "" {
+		toolCallID := idGen.GenerateToolCallID()
+		t.unidentifiedToolCalls[name] = append(t.unidentifiedToolCalls[name], toolCallID)
+		return toolCallID
+	}
+	t.toolCallMap[id] = id
+	return id
+}
+
+// recordToolCallMessage remembers the assistant message a tool call belongs to: the message it
+// interrupted, or, when no text preceded it, the message that will carry the answer
+func (t *runTranslation) recordToolCallMessage(toolCallID, parentMessageID string) {
+	if parentMessageID == "" {
+		parentMessageID = t.messageID
+	}
+	t.toolCallMessages[toolCallID] = parentMessageID
+}
+
+// toolCallMessageID returns the assistant message a tool call belongs to
+// Results of calls this run never started belong to the current message
+func (t *runTranslation) toolCallMessageID(toolCallID string) string {
+	if messageID, ok := t.toolCallMessages[toolCallID]; ok {
+		return messageID
+	}
+	return t.messageID
+}
+
+// toolResultMessageID returns the ID of the separate tool message carrying a call's result
+// It is derived from the run's message ID and the call, so a retried run keeps its IDs
+func (t *runTranslation) toolResultMessageID(toolCallID string) string {
+	return t.baseMessageID + "-tool-" + toolCallID
+}
+
+// responseToolCallID returns the AG-UI ID of the call a function response answers
+// Responses without an ID answer the oldest unanswered ID-less call of the same function
+func (t *runTranslation) responseToolCallID(id, name string) (string, bool) {
+	if id != "" {
+		toolCallID, ok := t.toolCallMap[id]
+		return toolCallID, ok
+	}
+	queued := t.unidentifiedToolCalls[name]
+	if len(queued) == 0 {
+		return "", false
+	}
+	t.unidentifiedToolCalls[name] = queued[1:]
+	return queued[0], true
+}
+
+// emitText emits assistant text, closing any thinking segment and opening the message on first use
+// In buffered mode the text is only collected until release
+func (t *runTranslation) emitText(delta string) {
+	t.endThinking()
+	t.responseBuilder.WriteString(delta)
+	if t.buffered {
+		return
+	}
+	if t.sentences != nil {
+		delta = t.sentences.push(delta)
+		if delta == "" {
+			return
+		}
+	}
+	t.sendText(delta)
+}
+
+// flushText sends any text held back by sentence chunking
+// Called before anything that must follow the text, such as a tool call
+func (t *runTranslation) flushText() {
+	if t.sentences == nil {
+		return
+	}
+	if text := t.sentences.flush(); text != "" {
+		t.sendText(text)
+	}
+}
+
+// release sends the (post-processed) buffered text as a single content event
+func (t *runTranslation) release(text string) {
+	t.endThinking()
+	t.buffered = false
+	if text != "" {
+		t.sendText(text)
+	}
+}
+
+// startTyping shows the typing indicator until the first output
+func (t *runTranslation) startTyping() {
+	t.typing = true
+	t.eventChan <- typingEvent(t.messageID, true)
+}
+
+// stopTyping hides the typing indicator if it is shown
+func (t *runTranslation) stopTyping() {
+	if !t.typing {
+		return
+	}
+	t.typing = false
+	t.eventChan <- typingEvent(t.messageID, false)
+}
+
+// typingEvent creates a typing indicator event for the run's assistant message
+func typingEvent(messageID string, typing bool) events.Event {
+	return events.NewCustomEvent(CustomEventTyping, events.WithValue(map[string]interface{}{
+		"messageId": messageID,
+		"typing":    typing,
+	}))
+}
+
+// sendText opens the assistant message on first use and sends a content event
+func (t *runTranslation) sendText(delta string) {
+	t.stopTyping()
+	if t.planning {
+		t.endMessage()
+	}
+	if !t.messageStarted {
+		t.eventChan <- events.NewTextMessageStartEvent(t.messageID, events.WithRole(t.role))
+		t.messageStarted = true
+	}
+	t.eventChan <- newSequencedTextMessageContentEvent(t.messageID, delta, t.textSequence)
+	t.textSequence++
+}
+
+// beginToolCall closes whatever is open before a tool call, so the call sits between text messages
+// instead of inside one; text after the call opens a new message with a fresh ID
+// Returns the ID of the interrupted message as the call's parent ("" if no message was open)
+func (t *runTranslation) beginToolCall() string {
+	t.flushText()
+	t.endThinking()
+	t.stopTyping()
+	if !t.messageStarted {
+		return ""
+	}
+	parentMessageID := t.messageID
+	t.endMessage()
+	return parentMessageID
+}
+
+// endMessage closes the open message; the next one gets the next numbered ID,
+// so a retried run produces the same IDs
+func (t *runTranslation) endMessage() {
+	t.eventChan <- events.NewTextMessageEndEvent(t.messageID)
+	t.messageStarted = false
+	t.planning = false
+	t.messages++
+	t.messageID = fmt.Sprintf("%s-%d", t.baseMessageID, t.messages)
+	t.textSequence = 0
+}
+
+// emitPlan emits narration that precedes a tool call as a plan segment: its own message,
+// announced by a plan event; it is not part of the response text
+func (t *runTranslation) emitPlan(delta string) {
+	t.flushText()
+	t.endThinking()
+	t.stopTyping()
+	if t.messageStarted && !t.planning {
+		t.endMessage()
+	}
+	if !t.messageStarted {
+		t.eventChan <- events.NewCustomEvent(CustomEventPlan, events.WithValue(map[string]interface{}{
+			"messageId": t.messageID,
+		}))
+		t.eventChan <- events.NewTextMessageStartEvent(t.messageID, events.WithRole(t.role))
+		t.messageStarted = true
+		t.planning = true
+	}
+	t.eventChan <- newSequencedTextMessageContentEvent(t.messageID, delta, t.textSequence)
+	t.textSequence++
+}
+
+// emitThought emits model reasoning inside a thinking segment
+func (t *runTranslation) emitThought(delta string) {
+	t.stopTyping()
+	if !t.thinking {
+		t.eventChan <- events.NewThinkingStartEvent()
+		t.eventChan <- events.NewThinkingTextMessageStartEvent()
+		t.thinking = true
+	}
+	t.eventChan <- events.NewThinkingTextMessageContentEvent(delta)
+}
+
+// endThinking closes the thinking segment if one is open
+func (t *runTranslation) endThinking() {
+	if !t.thinking {
+		return
+	}
+	t.eventChan <- events.NewThinkingTextMessageEndEvent()
+	t.eventChan <- events.NewThinkingEndEvent()
+	t.thinking = false
+}
+
+// closeToolCalls ends every started tool call that hasn't received its result
+func (t *runTranslation) closeToolCalls() {
+	for toolCallID := range t.startedToolCalls {
+		t.eventChan <- events.NewToolCallEndEvent(toolCallID)
+		delete(t.startedToolCalls, toolCallID)
+	}
+}
+
+// cancelToolCalls ends every started tool call that hasn't received its result because the run
+// was cancelled or timed out, announcing each with a tool_call_error event carrying the reason
+func (t *runTranslation) cancelToolCalls(reason FinishReason) {
+	for toolCallID := range t.startedToolCalls {
+		t.eventChan <- events.NewCustomEvent(CustomEventToolCallError, events.WithValue(map[string]interface{}{
+			"toolCallId": toolCallID,
+			"error":      string(reason),
+		}))
+		t.eventChan <- events.NewToolCallEndEvent(toolCallID)
+		delete(t.startedToolCalls, toolCallID)
+	}
+}
+
+// finish closes any open thinking segment and assistant message
+func (t *runTranslation) finish() {
+	t.stopTyping()
+	t.flushText()
+	t.endThinking()
+	if t.messageStarted {
+		t.eventChan <- events.NewTextMessageEndEvent(t.messageID)
+		t.messageStarted = false
+		t.planning = false
+	}
+}
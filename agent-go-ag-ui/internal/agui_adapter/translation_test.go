@@ -0,0 +1,190 @@
+package agui_adapter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/genai"
+)
+
+// translate feeds ADK events, one per step, through a fresh run translation and returns the AG-UI events
+func translate(t *testing.T, steps ...[]*genai.Part) []events.Event {
+	t.Helper()
+	a := newTestAdapter(nil, nil)
+	eventChan := make(chan events.Event, 100)
+	tr := newRunTranslation("msg-1", "assistant", eventChan, false, false)
+	for _, parts := range steps {
+		adkEvent := adksession.NewEvent("invocation-1")
+		adkEvent.Content = genai.NewContentFromParts(parts, genai.RoleModel)
+		if err := a.translateADKEvent(adkEvent, tr); err != nil {
+			t.Fatalf("translateADKEvent: %v", err)
+		}
+	}
+	tr.finish()
+	close(eventChan)
+
+	var evts []events.Event
+	for event := range eventChan {
+		evts = append(evts, event)
+	}
+	return evts
+}
+
+// lifecycle describes an event by its type and the IDs that thread it
+func lifecycle(event events.Event) string {
+	switch e := event.(type) {
+	case *events.TextMessageStartEvent:
+		return "TEXT_MESSAGE_START " + e.MessageID
+	case *SequencedTextMessageContentEvent:
+		return "TEXT_MESSAGE_CONTENT " + e.MessageID
+	case *events.TextMessageEndEvent:
+		return "TEXT_MESSAGE_END " + e.MessageID
+	case *events.ToolCallStartEvent:
+		parent := ""
+		if e.ParentMessageID != nil {
+			parent = *e.ParentMessageID
+		}
+		return "TOOL_CALL_START " + e.ToolCallID + " parent=" + parent
+	case *events.ToolCallArgsEvent:
+		return "TOOL_CALL_ARGS " + e.ToolCallID
+	case *events.ToolCallResultEvent:
+		return "TOOL_CALL_RESULT " + e.ToolCallID + " message=" + e.MessageID
+	case *events.ToolCallEndEvent:
+		return "TOOL_CALL_END " + e.ToolCallID
+	default:
+		return string(event.Type())
+	}
+}
+
+func TestTranslateToolCallLifecycle(t *testing.T) {
+	evts := translate(t,
+		[]*genai.Part{genai.NewPartFromText("Let me check.")},
+		[]*genai.Part{{FunctionCall: &genai.FunctionCall{ID: "call-1", Name: "get_time", Args: map[string]any{"tz": "UTC"}}}},
+		[]*genai.Part{{FunctionResponse: &genai.FunctionResponse{ID: "call-1", Name: "get_time", Response: map[string]any{"time": "12:00"}}}},
+		[]*genai.Part{genai.NewPartFromText("It is noon.")},
+	)
+
+	var got []string
+	for _, event := range evts {
+		got = append(got, lifecycle(event))
+	}
+	want := []string{
+		"TEXT_MESSAGE_START msg-1",
+		"TEXT_MESSAGE_CONTENT msg-1",
+		"TEXT_MESSAGE_END msg-1",
+		"TOOL_CALL_START call-1 parent=msg-1",
+		"TOOL_CALL_ARGS call-1",
+		"TOOL_CALL_RESULT call-1 message=msg-1-tool-call-1",
+		"TOOL_CALL_END call-1",
+		"TEXT_MESSAGE_START msg-1-1",
+		"TEXT_MESSAGE_CONTENT msg-1-1",
+		"TEXT_MESSAGE_END msg-1-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events =\n%v\nwant\n%v", got, want)
+	}
+	if args := evts[4].(*events.ToolCallArgsEvent).Delta; args != `{"tz":"UTC"}` {
+		t.Errorf("TOOL_CALL_ARGS delta = %s, want the call's arguments", args)
+	}
+	if result := evts[5].(*events.ToolCallResultEvent).Content; result != `{"time":"12:00"}` {
+		t.Errorf("TOOL_CALL_RESULT content = %s, want the function response", result)
+	}
+}
+
+func TestTranslateToolCallWithoutID(t *testing.T) {
+	// Without an ID the call gets a generated one, and its response is matched by function name
+	evts := translate(t,
+		[]*genai.Part{{FunctionCall: &genai.FunctionCall{Name: "get_time", Args: map[string]any{}}}},
+		[]*genai.Part{{FunctionResponse: &genai.FunctionResponse{Name: "get_time", Response: map[string]any{"time": "12:00"}}}},
+	)
+
+	want := []events.EventType{
+		events.EventTypeToolCallStart,
+		events.EventTypeToolCallArgs,
+		events.EventTypeToolCallResult,
+		events.EventTypeToolCallEnd,
+	}
+	if got := eventTypes(evts); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	start := evts[0].(*events.ToolCallStartEvent)
+	if start.ToolCallID == "" {
+		t.Fatal("TOOL_CALL_START has no tool call ID")
+	}
+	if start.ParentMessageID != nil {
+		t.Errorf("TOOL_CALL_START parent = %q, want none without preceding text", *start.ParentMessageID)
+	}
+	ids := []string{
+		evts[1].(*events.ToolCallArgsEvent).ToolCallID,
+		evts[2].(*events.ToolCallResultEvent).ToolCallID,
+		evts[3].(*events.ToolCallEndEvent).ToolCallID,
+	}
+	for _, id := range ids {
+		if id != start.ToolCallID {
+			t.Errorf("tool call IDs = %v, want %s throughout", ids, start.ToolCallID)
+			break
+		}
+	}
+}
+
+func TestTranslateMixedTextAndFunctionCall(t *testing.T) {
+	call := &genai.Part{FunctionCall: &genai.FunctionCall{ID: "call-1", Name: "get_time", Args: map[string]any{}}}
+
+	tests := []struct {
+		name  string
+		parts []*genai.Part
+		want  []string
+	}{
+		{
+			name:  "text, call, text",
+			parts: []*genai.Part{genai.NewPartFromText("Checking."), call, genai.NewPartFromText("Done.")},
+			want: []string{
+				"TEXT_MESSAGE_START msg-1",
+				"TEXT_MESSAGE_CONTENT msg-1",
+				"TEXT_MESSAGE_END msg-1",
+				"TOOL_CALL_START call-1 parent=msg-1",
+				"TOOL_CALL_ARGS call-1",
+				"TEXT_MESSAGE_START msg-1-1",
+				"TEXT_MESSAGE_CONTENT msg-1-1",
+				"TEXT_MESSAGE_END msg-1-1",
+			},
+		},
+		{
+			name:  "call, text",
+			parts: []*genai.Part{call, genai.NewPartFromText("Done.")},
+			want: []string{
+				"TOOL_CALL_START call-1 parent=",
+				"TOOL_CALL_ARGS call-1",
+				"TEXT_MESSAGE_START msg-1",
+				"TEXT_MESSAGE_CONTENT msg-1",
+				"TEXT_MESSAGE_END msg-1",
+			},
+		},
+		{
+			name:  "text, call",
+			parts: []*genai.Part{genai.NewPartFromText("Checking."), call},
+			want: []string{
+				"TEXT_MESSAGE_START msg-1",
+				"TEXT_MESSAGE_CONTENT msg-1",
+				"TEXT_MESSAGE_END msg-1",
+				"TOOL_CALL_START call-1 parent=msg-1",
+				"TOOL_CALL_ARGS call-1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// All parts arrive in a single ADK event
+			var got []string
+			for _, event := range translate(t, tt.parts) {
+				got = append(got, lifecycle(event))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("events =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,22 @@ package agui_adapter
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"reflect"
 
 	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+
+	"agent-go-ag-ui/internal/transport"
 )
 
+// ForwardedPropAssistantRole overrides the role emitted on TEXT_MESSAGE_START for a single run
+const ForwardedPropAssistantRole = "assistantRole"
+
+// ForwardedPropHeaders receives the forwarded request headers when FORWARD_HEADERS_TO_PROPS is enabled
+const ForwardedPropHeaders = "headers"
+
 // Re-export Message type from SDK for convenience (no duplication)
 type Message = events.Message
 
@@ -21,12 +32,181 @@ type RunAgentInput struct {
 	ForwardedProps map[string]interface{}   `json:"forwardedProps"`
 }
 
+// DecodeRunAgentInput decodes a JSON request body
+// Fields of the wrong JSON type (e.g. a forwardedProps array) get a descriptive error
+// instead of the decoder's Go-typed message
+func DecodeRunAgentInput(body io.Reader) (*RunAgentInput, error) {
+	var input RunAgentInput
+	if err := json.NewDecoder(body).Decode(&input); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return nil, fmt.Errorf("'%s' must be %s, got %s", typeErr.Field, jsonKind(typeErr.Type), typeErr.Value)
+		}
+		return nil, err
+	}
+	return &input, nil
+}
+
+// jsonKind describes the JSON type expected for a Go type
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	default:
+		return "a valid value"
+	}
+}
+
+// ValidateForwardedPropsSize rejects forwardedProps whose JSON encoding exceeds maxBytes (0 = unlimited)
+// It runs during request parsing, before the run starts
+func (r *RunAgentInput) ValidateForwardedPropsSize(maxBytes int) error {
+	if maxBytes <= 0 || len(r.ForwardedProps) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(r.ForwardedProps)
+	if err != nil {
+		return fmt.Errorf("forwardedProps is not JSON-serializable: %w", err)
+	}
+	if len(data) > maxBytes {
+		return fmt.Errorf("forwardedProps is %d bytes, exceeding the %d byte limit", len(data), maxBytes)
+	}
+	return nil
+}
+
+// knownMessageFields lists the message keys this server understands; tool call fields are
+// accepted in both the AG-UI (camelCase) and the snake_case spelling
+var knownMessageFields = map[string]bool{
+	"id":           true,
+	"role":         true,
+	"content":      true,
+	"name":         true,
+	"toolCalls":    true,
+	"toolCallId":   true,
+	"tool_calls":   true,
+	"tool_call_id": true,
+}
+
+// ValidateMessageFields rejects messages with keys outside knownMessageFields when strict is set
+// Lenient (the default) ignores unknown keys; strict catches client typos such as "contnet"
+func (r *RunAgentInput) ValidateMessageFields(strict bool) error {
+	if !strict {
+		return nil
+	}
+	for i, msg := range r.Messages {
+		for key := range msg {
+			if !knownMessageFields[key] {
+				return fmt.Errorf("message at index %d has unknown field '%s'", i, key)
+			}
+		}
+	}
+	return nil
+}
+
 // Validate validates the RunAgentInput structure
 // This should be called early in the request flow (in handlers) before processing
 func (r *RunAgentInput) Validate() error {
 	// ThreadID and RunID are optional (will be generated if missing)
 	// State, Tools, Context, and ForwardedProps are optional
 
+	// IDs flow into session keys and logs, so restrict them to a safe charset
+	if err := ValidateID("threadId", r.ThreadID); err != nil {
+		return err
+	}
+	if err := ValidateID("runId", r.RunID); err != nil {
+		return err
+	}
+
+	if err := validateMessageID(r.ForwardedProps); err != nil {
+		return err
+	}
+
+	// Validate the per-request assistant role override
+	if role, exists := r.ForwardedProps[ForwardedPropAssistantRole]; exists {
+		roleStr, ok := role.(string)
+		if !ok {
+			return fmt.Errorf("forwardedProps '%s' must be a string", ForwardedPropAssistantRole)
+		}
+		if err := ValidateTextMessageRole(roleStr); err != nil {
+			return fmt.Errorf("forwardedProps '%s': %w", ForwardedPropAssistantRole, err)
+		}
+	}
+
+	// The agent is selected by name
+	if value, exists := r.ForwardedProps[ForwardedPropAgent]; exists {
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("forwardedProps '%s' must be a string", ForwardedPropAgent)
+		}
+	}
+
+	if value, exists := r.ForwardedProps[ForwardedPropStateSyncOnly]; exists {
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("forwardedProps '%s' must be a boolean", ForwardedPropStateSyncOnly)
+		}
+	}
+
+	// The response language is a BCP-47 tag; unsupported tags fall back to the default
+	for _, key := range []string{ForwardedPropLocale, ForwardedPropLanguage} {
+		if value, exists := r.ForwardedProps[key]; exists {
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("forwardedProps '%s' must be a string", key)
+			}
+		}
+	}
+
+	// Sampling overrides must be in the model's range
+	if err := validateSampling(r.ForwardedProps); err != nil {
+		return err
+	}
+
+	// JSON mode takes an optional schema object
+	if err := validateResponseFormat(r.ForwardedProps); err != nil {
+		return err
+	}
+
+	// The callback URL must not point at internal addresses
+	if value, exists := r.ForwardedProps[ForwardedPropCallbackURL]; exists {
+		callbackURL, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("forwardedProps '%s' must be a string", ForwardedPropCallbackURL)
+		}
+		if err := ValidateCallbackURL(callbackURL); err != nil {
+			return err
+		}
+	}
+
+	// Client tools are declared to the model, so each needs a name and a schema object
+	if err := validateClientTools(r.Tools); err != nil {
+		return err
+	}
+
+	// State must be storable as JSON (NaN/Inf are coerced later, excessive nesting is rejected)
+	if _, err := transport.NormalizeState(r.State); err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+
+	// Validate the reserved state reset key
+	if reset, exists := r.State[transport.StateResetKey]; exists {
+		keys, ok := reset.([]interface{})
+		if !ok {
+			return fmt.Errorf("state key '%s' must be an array of strings", transport.StateResetKey)
+		}
+		for i, key := range keys {
+			if _, ok := key.(string); !ok {
+				return fmt.Errorf("state key '%s' has a non-string entry at index %d", transport.StateResetKey, i)
+			}
+		}
+	}
+
 	// Validate messages (most important validation)
 	if err := ValidateMessages(r.Messages); err != nil {
 		return fmt.Errorf("messages validation failed: %w", err)
@@ -82,6 +262,14 @@ func (r *RunAgentInput) GetContext() []interface{} {
 	return r.Context
 }
 
+// AssistantRole returns the per-request TEXT_MESSAGE_START role override, or def if none is set
+func (r *RunAgentInput) AssistantRole(def string) string {
+	if role, ok := r.ForwardedProps[ForwardedPropAssistantRole].(string); ok && role != "" {
+		return role
+	}
+	return def
+}
+
 // GetForwardedProps returns the forwarded props map, initializing it if nil
 func (r *RunAgentInput) GetForwardedProps() map[string]interface{} {
 	if r.ForwardedProps == nil {
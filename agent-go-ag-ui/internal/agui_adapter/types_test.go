@@ -0,0 +1,116 @@
+package agui_adapter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		threadID string
+		runID    string
+		wantErr  string
+	}{
+		{name: "generated", threadID: "", runID: ""},
+		{name: "safe charset", threadID: "thread_1.a:b-c", runID: "RUN-42"},
+		{name: "max length", threadID: strings.Repeat("t", maxIDLength)},
+		{name: "too long", threadID: strings.Repeat("t", maxIDLength+1), wantErr: "'threadId' exceeds"},
+		{name: "path traversal", threadID: "../../etc/passwd", wantErr: "'threadId' contains invalid characters"},
+		{name: "namespace separator", threadID: "tenant/thread", wantErr: "'threadId' contains invalid characters"},
+		{name: "log injection", runID: "run-1\nlevel=error msg=forged", wantErr: "'runId' contains invalid characters"},
+		{name: "carriage return", runID: "run-1\r", wantErr: "'runId' contains invalid characters"},
+		{name: "sql quote", threadID: "t' OR '1'='1", wantErr: "'threadId' contains invalid characters"},
+		{name: "html", runID: "<script>alert(1)</script>", wantErr: "'runId' contains invalid characters"},
+		{name: "whitespace", threadID: "thread 1", wantErr: "'threadId' contains invalid characters"},
+		{name: "null byte", threadID: "thread\x00", wantErr: "'threadId' contains invalid characters"},
+		{name: "unicode", runID: "rün", wantErr: "'runId' contains invalid characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &RunAgentInput{ThreadID: tt.threadID, RunID: tt.runID}
+			err := input.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateForwardedPropsSize(t *testing.T) {
+	props := map[string]interface{}{"notes": strings.Repeat("x", 100)}
+	size := len(`{"notes":""}`) + 100
+
+	tests := []struct {
+		name     string
+		props    map[string]interface{}
+		maxBytes int
+		wantErr  bool
+	}{
+		{name: "unlimited", props: props, maxBytes: 0},
+		{name: "no props", props: nil, maxBytes: 1},
+		{name: "at the limit", props: props, maxBytes: size},
+		{name: "over the limit", props: props, maxBytes: size - 1, wantErr: true},
+		{name: "nested", props: map[string]interface{}{"a": map[string]interface{}{"b": strings.Repeat("y", 64)}}, maxBytes: 32, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &RunAgentInput{ForwardedProps: tt.props}
+			err := input.ValidateForwardedPropsSize(tt.maxBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateForwardedPropsSize(%d) = %v, want error %v", tt.maxBytes, err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "byte limit") {
+				t.Errorf("error = %q, want it to name the limit", err)
+			}
+		})
+	}
+}
+
+func TestValidateContentTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		wantErr string
+	}{
+		{name: "user string", message: `{"id":"1","role":"user","content":"hi"}`},
+		{name: "user array", message: `{"id":"1","role":"user","content":[{"type":"text","text":"hi"}]}`},
+		{name: "system string", message: `{"id":"1","role":"system","content":"be brief"}`},
+		{name: "tool without content", message: `{"id":"1","role":"tool","toolCallId":"call-1"}`},
+		{name: "user number", message: `{"id":"1","role":"user","content":42}`, wantErr: "for role 'user' (expected string or array, got number)"},
+		{name: "user boolean", message: `{"id":"1","role":"user","content":true}`, wantErr: "for role 'user' (expected string or array, got boolean)"},
+		{name: "assistant number", message: `{"id":"1","role":"assistant","content":1.5}`, wantErr: "for role 'assistant' (expected string or array, got number)"},
+		{name: "user object", message: `{"id":"1","role":"user","content":{"text":"hi"}}`, wantErr: "got object"},
+		{name: "system boolean", message: `{"id":"1","role":"system","content":false}`, wantErr: "for role 'system' (expected string, got boolean)"},
+		{name: "developer number", message: `{"id":"1","role":"developer","content":0}`, wantErr: "for role 'developer' (expected string, got number)"},
+		{name: "tool number", message: `{"id":"1","role":"tool","toolCallId":"call-1","content":7}`, wantErr: "for role 'tool' (expected string, got number)"},
+		{name: "system array", message: `{"id":"1","role":"system","content":["be brief"]}`, wantErr: "for role 'system' (expected string, got array)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := DecodeRunAgentInput(strings.NewReader(`{"messages":[` + tt.message + `]}`))
+			if err != nil {
+				t.Fatalf("DecodeRunAgentInput: %v", err)
+			}
+			err = input.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
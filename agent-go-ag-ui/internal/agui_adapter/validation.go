@@ -1,6 +1,48 @@
 package agui_adapter
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxIDLength bounds client-supplied thread and run IDs
+const maxIDLength = 128
+
+// idPattern is the safe charset for IDs that flow into session keys and logs
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// ValidateID validates a client-supplied thread or run ID
+// Empty IDs are allowed (they are generated); anything else must be short and use a safe charset
+func ValidateID(field, id string) error {
+	if id == "" {
+		return nil
+	}
+	if len(id) > maxIDLength {
+		return fmt.Errorf("'%s' exceeds %d characters", field, maxIDLength)
+	}
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("'%s' contains invalid characters (allowed: letters, digits, '_', '.', ':', '-')", field)
+	}
+	return nil
+}
+
+// textMessageRoles are the roles AG-UI allows on TEXT_MESSAGE_START
+var textMessageRoles = map[string]bool{
+	"assistant": true,
+	"user":      true,
+	"system":    true,
+	"developer": true,
+}
+
+// ValidateTextMessageRole validates a role for the emitted TEXT_MESSAGE_START
+func ValidateTextMessageRole(role string) error {
+	if !textMessageRoles[role] {
+		return fmt.Errorf("invalid text message role %q (allowed: assistant, user, system, developer)", role)
+	}
+	return nil
+}
 
 // ValidateMessages validates that messages have the required structure
 // This is shared across all transport handlers
@@ -39,17 +81,39 @@ func ValidateMessages(messages []map[string]interface{}) error {
 		}
 
 		// Check for content field (required for user and assistant messages)
-		if roleStr == "user" || roleStr == "assistant" {
-			content, hasContent := msg["content"]
-			if !hasContent || content == nil {
-				return fmt.Errorf("message at index %d missing required field 'content' for role '%s'", i, roleStr)
-			}
+		content, hasContent := msg["content"]
+		if (roleStr == "user" || roleStr == "assistant") && (!hasContent || content == nil) {
+			return fmt.Errorf("message at index %d missing required field 'content' for role '%s'", i, roleStr)
+		}
 
-			// Content should be a string or array
-			if _, ok := content.(string); !ok {
-				if _, ok := content.([]interface{}); !ok {
-					return fmt.Errorf("message at index %d has invalid 'content' type (expected string or array)", i)
+		// Content, where present, must be a string; user and assistant messages may also use an array of items
+		if hasContent && content != nil {
+			switch content.(type) {
+			case string:
+			case []interface{}:
+				if roleStr != "user" && roleStr != "assistant" {
+					return fmt.Errorf("message at index %d has invalid 'content' type for role '%s' (expected string, got array)", i, roleStr)
 				}
+			default:
+				expected := "string"
+				if roleStr == "user" || roleStr == "assistant" {
+					expected = "string or array"
+				}
+				return fmt.Errorf("message at index %d has invalid 'content' type for role '%s' (expected %s, got %s)", i, roleStr, expected, jsonValueKind(content))
+			}
+		}
+
+		// System and developer messages carry instructions for the run, in 'content'
+		if roleStr == "system" || roleStr == "developer" {
+			if text, _ := content.(string); strings.TrimSpace(text) == "" {
+				return fmt.Errorf("message at index %d has no usable instruction for role '%s' ('content' must be a non-empty string)", i, roleStr)
+			}
+		}
+
+		// Tool messages identify the call they answer; the function name is optional
+		if roleStr == "tool" && messageToolCallID(msg) == "" {
+			if name, _ := msg["name"].(string); name == "" {
+				return fmt.Errorf("message at index %d missing required field 'toolCallId' for role 'tool'", i)
 			}
 		}
 	}
@@ -57,3 +121,22 @@ func ValidateMessages(messages []map[string]interface{}) error {
 	return nil
 }
 
+// jsonValueKind names the JSON type of a decoded value
+func jsonValueKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, int, int64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,261 @@
+package agui_adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// ForwardedPropCallbackURL is a URL the final run result is POSTed to, in addition to the stream
+const ForwardedPropCallbackURL = "callbackUrl"
+
+// webhookBackoff is the delay before the first delivery retry, doubled on each further retry
+const webhookBackoff = time.Second
+
+// errNonPublicAddress rejects callback URLs that resolve to internal addresses
+var errNonPublicAddress = errors.New("callbackUrl must resolve to a public address")
+
+// nonPublicPrefixes are address ranges not covered by the netip predicates but still internal
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, may embed an internal IPv4
+}
+
+// isPublicAddr reports whether addr is a globally routable unicast address
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateCallbackURL checks that a callback URL is an absolute http(s) URL without credentials,
+// and that its host isn't obviously internal; resolved addresses are checked again when dialing
+func ValidateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("callbackUrl is not a valid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("callbackUrl scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.User != nil {
+		return errors.New("callbackUrl must not contain credentials")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return errors.New("callbackUrl must have a host")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errNonPublicAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddr(addr) {
+		return errNonPublicAddress
+	}
+	return nil
+}
+
+// publicOnlyTransport is an HTTP transport that only connects to public addresses
+// Addresses are checked after DNS resolution, on every connection (redirects included), which
+// also covers rebinding; denied connections fail with errDenied. The environment proxy is not used
+func publicOnlyTransport(timeout time.Duration, errDenied error) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !isPublicAddr(addrPort.Addr()) {
+				return errDenied
+			}
+			return nil
+		},
+	}
+	return &http.Transport{DialContext: dialer.DialContext}
+}
+
+// webhookNotifier POSTs run results to client-supplied callback URLs
+type webhookNotifier struct {
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+// newWebhookNotifier creates a notifier, or nil if timeout is zero (callbacks disabled)
+// Connections are refused to non-public addresses after DNS resolution, which also covers
+// rebinding; redirects are not followed and the environment proxy is not used
+func newWebhookNotifier(timeout time.Duration, attempts int) *webhookNotifier {
+	if timeout <= 0 {
+		return nil
+	}
+	return &webhookNotifier{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: publicOnlyTransport(timeout, errNonPublicAddress),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		attempts: attempts,
+		backoff:  webhookBackoff,
+	}
+}
+
+// webhookToolCall is a tool call reported to a callback URL
+type webhookToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Result    string `json:"result,omitempty"`
+}
+
+// webhookPayload is the body POSTed to a callback URL once a run ends
+type webhookPayload struct {
+	ThreadID     string             `json:"threadId"`
+	RunID        string             `json:"runId"`
+	Status       string             `json:"status"` // "finished" or "error"
+	FinishReason string             `json:"finishReason,omitempty"`
+	Error        string             `json:"error,omitempty"`
+	Text         string             `json:"text"`
+	ToolCalls    []*webhookToolCall `json:"toolCalls"`
+}
+
+// webhookCollector passes events on while collecting the run result for a callback
+type webhookCollector struct {
+	next      EventSender
+	payload   webhookPayload
+	text      strings.Builder
+	toolCalls map[string]*webhookToolCall
+	// ended is set once RUN_FINISHED or RUN_ERROR went through
+	ended bool
+}
+
+// newWebhookCollector creates a collector for one run
+func newWebhookCollector(next EventSender, threadID, runID string) *webhookCollector {
+	return &webhookCollector{
+		next:      next,
+		payload:   webhookPayload{ThreadID: threadID, RunID: runID, ToolCalls: []*webhookToolCall{}},
+		toolCalls: make(map[string]*webhookToolCall),
+	}
+}
+
+func (c *webhookCollector) SendEvent(event events.Event) error {
+	switch e := event.(type) {
+	case *SequencedTextMessageContentEvent:
+		c.text.WriteString(e.Delta)
+	case *events.TextMessageContentEvent:
+		c.text.WriteString(e.Delta)
+	case *events.ToolCallStartEvent:
+		toolCall := &webhookToolCall{ID: e.ToolCallID, Name: e.ToolCallName}
+		c.toolCalls[e.ToolCallID] = toolCall
+		c.payload.ToolCalls = append(c.payload.ToolCalls, toolCall)
+	case *events.ToolCallArgsEvent:
+		if toolCall, ok := c.toolCalls[e.ToolCallID]; ok {
+			toolCall.Arguments += e.Delta
+		}
+	case *events.ToolCallResultEvent:
+		if toolCall, ok := c.toolCalls[e.ToolCallID]; ok {
+			toolCall.Result = e.Content
+		}
+	case *StructuredToolCallArgsEvent:
+		if toolCall, ok := c.toolCalls[e.ToolCallID]; ok {
+			toolCall.Arguments += e.Delta
+		}
+	case *StructuredToolCallResultEvent:
+		if toolCall, ok := c.toolCalls[e.ToolCallID]; ok {
+			toolCall.Result = e.Content
+		}
+	case *events.RunFinishedEvent:
+		c.ended = true
+		c.payload.Status = "finished"
+		if result, ok := e.Result.(map[string]interface{}); ok {
+			c.payload.FinishReason = fmt.Sprint(result["finishReason"])
+		}
+	case *events.RunErrorEvent:
+		c.ended = true
+		c.payload.Status = "error"
+		c.payload.Error = e.Message
+	}
+	return c.next.SendEvent(event)
+}
+
+func (c *webhookCollector) SendRunError(runID string, err error) error {
+	c.ended = true
+	c.payload.Status = "error"
+	c.payload.Error = err.Error()
+	return c.next.SendRunError(runID, err)
+}
+
+// notify delivers the collected result in the background, if the run reached RUN_FINISHED or RUN_ERROR
+// Delivery is retried on network errors, 429 and 5xx responses
+func (n *webhookNotifier) notify(requestID, callbackURL string, c *webhookCollector) {
+	if !c.ended {
+		return
+	}
+	c.payload.Text = c.text.String()
+	body, err := json.Marshal(c.payload)
+	if err != nil {
+		log.Printf("[%s] Failed to encode webhook payload: %v", requestID, err)
+		return
+	}
+
+	go func() {
+		backoff := n.backoff
+		for attempt := 1; attempt <= n.attempts; attempt++ {
+			retry, err := n.post(callbackURL, body)
+			if err == nil {
+				return
+			}
+			log.Printf("[%s] Webhook delivery attempt %d/%d failed: %v", requestID, attempt, n.attempts, err)
+			if !retry || attempt == n.attempts {
+				return
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}()
+}
+
+// post sends one delivery attempt; retry reports whether a failure is worth retrying
+func (n *webhookNotifier) post(callbackURL string, body []byte) (retry bool, err error) {
+	// Not tied to the request: the client may already be gone
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errNonPublicAddress), err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("callback returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("callback returned %s", resp.Status)
+	}
+}
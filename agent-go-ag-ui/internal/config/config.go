@@ -2,7 +2,20 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"google.golang.org/genai"
 )
 
 // Config holds the application configuration
@@ -10,12 +23,199 @@ type Config struct {
 	GoogleAPIKey string
 	Port         string
 	AppName      string
+
+	// AuthToken is the bearer token required by the operator endpoints (pprof, metrics)
+	AuthToken string
+	// AuthTokens maps client bearer tokens to the principal they authenticate; when set, the run
+	// endpoints require one of them (empty = every caller is the anonymous principal)
+	AuthTokens map[string]string
+	// EnablePprof registers /debug/pprof behind AuthToken
+	EnablePprof bool
+
+	// EnableSSE and EnableConnect register the transports; at least one is enabled
+	EnableSSE     bool
+	EnableConnect bool
+
+	// RequestIDHeader is the header carrying the request correlation ID
+	RequestIDHeader string
+
+	// TrustedProxies are the peers whose X-Forwarded-For/X-Real-IP headers name the client
+	TrustedProxies []netip.Prefix
+
+	// ThreadNamespace prefixes thread IDs before they key sessions and state ("" = none)
+	ThreadNamespace string
+	// ThreadNamespaceHeader names a trusted request header whose value replaces ThreadNamespace
+	ThreadNamespaceHeader string
+
+	// EnableThinking returns the model's thought summaries as THINKING events
+	EnableThinking bool
+	// SafetySettings overrides the model's default safety thresholds (nil = model defaults)
+	SafetySettings []*genai.SafetySetting
+
+	// EnableRunFanOut lets additional SSE subscribers attach to in-progress runs
+	EnableRunFanOut bool
+	// FanOutReplay is "start" (replay the whole run) or "join" (live events only)
+	FanOutReplay string
+
+	// MaxConcurrentRuns caps concurrently executing runs (0 = unlimited)
+	MaxConcurrentRuns int
+
+	// MaxStateBytes caps the JSON size of each thread's state (0 = unlimited)
+	MaxStateBytes int
+	// MaxConnections caps concurrently open client connections (0 = unlimited)
+	MaxConnections int
+	// MaxForwardedPropsBytes caps the JSON size of a request's forwardedProps (0 = unlimited)
+	MaxForwardedPropsBytes int
+	// StrictMessageFields rejects request messages with keys other than the known message fields
+	StrictMessageFields bool
+
+	// ConnectKeepAlive is the idle interval after which a heartbeat is sent on Connect streams (0 = disabled)
+	ConnectKeepAlive time.Duration
+
+	// SessionRetryAttempts is the number of attempts for transient session backend errors
+	SessionRetryAttempts int
+	// SessionRetryBackoff is the initial delay between session retries
+	SessionRetryBackoff time.Duration
+	// SessionUserIsolation rejects resuming a thread that another principal created
+	SessionUserIsolation bool
+
+	// ModelProxyURL routes model requests through this proxy instead of the HTTPS_PROXY environment (nil = environment)
+	ModelProxyURL *url.URL
+
+	// ModelRetryAttempts is the number of attempts for model calls that fail before producing any content
+	ModelRetryAttempts int
+	// ModelRetryBackoff is the initial delay between model retries
+	ModelRetryBackoff time.Duration
+
+	// BufferResponse holds the assistant text back until it is complete and post-processed
+	BufferResponse bool
+	// ResponseBlocklist lists words masked in buffered responses
+	ResponseBlocklist []string
+	// ResponseStripPatterns lists patterns whose matches are removed from buffered responses
+	ResponseStripPatterns []*regexp.Regexp
+
+	// TextChunking is "token" (send text as it streams) or "sentence" (send whole sentences)
+	TextChunking string
+	// EmptyResponse is "fallback" (answer with the fallback text) or "error" (RUN_ERROR)
+	// for a model stream that ends without a single event
+	EmptyResponse string
+	// ToolResultMessageMode is "separate" (each TOOL_CALL_RESULT gets its own tool message ID) or
+	// "assistant" (results reference the assistant message that made the call)
+	ToolResultMessageMode string
+
+	// AssistantRole is the role emitted on TEXT_MESSAGE_START
+	AssistantRole string
+
+	// MaxToolCalls stops a run with RUN_ERROR once it starts more tool calls than this (0 = unlimited)
+	MaxToolCalls int
+
+	// AgentsConfigPath is a YAML/JSON file defining the selectable agents (empty = the built-in agent)
+	AgentsConfigPath string
+	// EnabledTools lists the tools of the built-in agent by name (empty = its default tools)
+	EnabledTools []string
+
+	// TranscriptPath replaces the model with a replayed JSON transcript (for load testing)
+	TranscriptPath string
+
+	// ImageMaxBytes caps the size of each image in message content
+	ImageMaxBytes int64
+	// ImageFetchTimeout bounds fetching image URLs (0 = image URLs are rejected)
+	ImageFetchTimeout time.Duration
+
+	// InitialGreeting is streamed as an assistant message on empty-messages requests (empty = disabled)
+	InitialGreeting string
+
+	// SupportedLocales lists the BCP-47 response languages a request may select (empty = no language instruction)
+	SupportedLocales []string
+	// DefaultLocale is used when a request selects no supported language
+	DefaultLocale string
+
+	// Warmup checks the model is reachable at startup; readiness fails until it is
+	Warmup bool
+	// WarmupTimeout bounds each warmup attempt
+	WarmupTimeout time.Duration
+
+	// ThreadTTL forgets thread state unused for this long (0 = kept forever)
+	ThreadTTL time.Duration
+
+	// FinalStateSnapshot applies tool state changes to the thread state and sends a STATE_SNAPSHOT at run end when it changed
+	FinalStateSnapshot bool
+	// StreamStateDeltas sends a STATE_DELTA whenever tools change the thread state during a run
+	StreamStateDeltas bool
+
+	// LogEvents logs every emitted AG-UI event (debugging aid)
+	LogEvents bool
+	// LogEventBodies keeps message text and tool arguments/results in event logs instead of masking them
+	LogEventBodies bool
+
+	// ForwardHeaders lists request headers copied into the run context for tools (canonical form)
+	ForwardHeaders []string
+	// ForwardHeadersToProps also copies the forwarded headers into forwardedProps
+	ForwardHeadersToProps bool
+
+	// AgentTimeout bounds each agent run; runs that hit it finish with what was produced so far
+	AgentTimeout time.Duration
+	// MaxStreamDuration closes SSE and Connect streams with RUN_ERROR after this long (0 = unlimited)
+	MaxStreamDuration time.Duration
+
+	// SSEErrorAsHTTP answers SSE runs that fail before anything was streamed with an HTTP error instead of RUN_ERROR
+	SSEErrorAsHTTP bool
+
+	// EmitTyping sends CUSTOM typing events around the wait for the model's first output
+	EmitTyping bool
+
+	// ModerationErrorCode is the RUN_ERROR code of user input rejected by moderation
+	ModerationErrorCode string
+
+	// ConsumeAfterFinalResponse keeps reading agent events after the first final response,
+	// until the agent's stream ends
+	ConsumeAfterFinalResponse bool
+
+	// AllowContinueRuns runs the agent on the session history when a request has no new user turn
+	AllowContinueRuns bool
+
+	// PlanSegments streams the narration preceding a tool call as its own "plan" message
+	PlanSegments bool
+
+	// StructuredToolEvents adds parsed tool arguments and results as objects to TOOL_CALL_ARGS/TOOL_CALL_RESULT
+	StructuredToolEvents bool
+
+	// SSEEventNames writes each event's AG-UI type as the SSE "event:" name
+	SSEEventNames bool
+
+	// IdempotencyTTL is how long completed runs are replayed for retried requests with the same Idempotency-Key (0 = disabled)
+	IdempotencyTTL time.Duration
+
+	// DefaultTemperature is the sampling temperature of every model call (nil = model default)
+	DefaultTemperature *float32
+	// DefaultTopP is the nucleus sampling probability of every model call (nil = model default)
+	DefaultTopP *float32
+	// StopSequences end generation when the model outputs one of them
+	StopSequences []string
+
+	// WebhookTimeout bounds each callbackUrl delivery attempt (0 = callbackUrl is rejected)
+	WebhookTimeout time.Duration
+	// WebhookAttempts is the number of callbackUrl delivery attempts
+	WebhookAttempts int
+}
+
+// sensitiveHeaders carry credentials and are only forwarded with FORWARD_SENSITIVE_HEADERS
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Goog-Api-Key":      true,
 }
 
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
+	// A replayed transcript doesn't call the model, so it needs no API key
+	transcriptPath := os.Getenv("TRANSCRIPT_PATH")
+
 	apiKey := os.Getenv("GOOGLE_API_KEY")
-	if apiKey == "" {
+	if apiKey == "" && transcriptPath == "" {
 		return nil, errors.New("GOOGLE_API_KEY environment variable is required")
 	}
 
@@ -29,9 +229,644 @@ func Load() (*Config, error) {
 		appName = "agent-go-ag-ui"
 	}
 
+	authToken := os.Getenv("AUTH_TOKEN")
+
+	authTokens, err := parseAuthTokens(getEnvList("AUTH_TOKENS"))
+	if err != nil {
+		return nil, err
+	}
+
+	enablePprof, err := getEnvBool("ENABLE_PPROF", false)
+	if err != nil {
+		return nil, err
+	}
+	if enablePprof && authToken == "" {
+		return nil, errors.New("ENABLE_PPROF requires AUTH_TOKEN to be set")
+	}
+
+	enableSSE, err := getEnvBool("ENABLE_SSE", true)
+	if err != nil {
+		return nil, err
+	}
+	enableConnect, err := getEnvBool("ENABLE_CONNECT", true)
+	if err != nil {
+		return nil, err
+	}
+	if !enableSSE && !enableConnect {
+		return nil, errors.New("at least one of ENABLE_SSE and ENABLE_CONNECT must be enabled")
+	}
+
+	requestIDHeader := os.Getenv("REQUEST_ID_HEADER")
+	if requestIDHeader == "" {
+		requestIDHeader = "X-Request-ID"
+	}
+
+	var trustedProxies []netip.Prefix
+	for _, entry := range getEnvList("TRUSTED_PROXIES") {
+		prefix, err := parsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES must be a list of CIDRs or IP addresses, got %q", entry)
+		}
+		trustedProxies = append(trustedProxies, prefix)
+	}
+
+	threadNamespace := os.Getenv("THREAD_NAMESPACE")
+	threadNamespaceHeader := os.Getenv("THREAD_NAMESPACE_HEADER")
+
+	enableThinking, err := getEnvBool("ENABLE_THINKING", false)
+	if err != nil {
+		return nil, err
+	}
+
+	safetySettings, err := parseSafetySettings(os.Getenv("SAFETY_SETTINGS"))
+	if err != nil {
+		return nil, err
+	}
+
+	enableRunFanOut, err := getEnvBool("ENABLE_RUN_FANOUT", false)
+	if err != nil {
+		return nil, err
+	}
+
+	fanOutReplay := os.Getenv("FANOUT_REPLAY")
+	if fanOutReplay == "" {
+		fanOutReplay = "start"
+	}
+	if fanOutReplay != "start" && fanOutReplay != "join" {
+		return nil, fmt.Errorf("FANOUT_REPLAY must be \"start\" or \"join\", got %q", fanOutReplay)
+	}
+
+	maxConcurrentRuns, err := getEnvInt("MAX_CONCURRENT_RUNS", 0)
+	if err != nil {
+		return nil, err
+	}
+	if maxConcurrentRuns < 0 {
+		return nil, fmt.Errorf("MAX_CONCURRENT_RUNS must not be negative, got %d", maxConcurrentRuns)
+	}
+
+	maxStateBytes, err := getEnvInt("MAX_STATE_BYTES", 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	if maxStateBytes < 0 {
+		return nil, fmt.Errorf("MAX_STATE_BYTES must not be negative, got %d", maxStateBytes)
+	}
+
+	maxConnections, err := getEnvInt("MAX_CONNECTIONS", 0)
+	if err != nil {
+		return nil, err
+	}
+	if maxConnections < 0 {
+		return nil, fmt.Errorf("MAX_CONNECTIONS must not be negative, got %d", maxConnections)
+	}
+
+	maxForwardedPropsBytes, err := getEnvInt("MAX_FORWARDED_PROPS_BYTES", 64<<10)
+	if err != nil {
+		return nil, err
+	}
+	if maxForwardedPropsBytes < 0 {
+		return nil, fmt.Errorf("MAX_FORWARDED_PROPS_BYTES must not be negative, got %d", maxForwardedPropsBytes)
+	}
+
+	strictMessageFields, err := getEnvBool("STRICT_MESSAGE_FIELDS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	connectKeepAlive, err := getEnvDuration("CONNECT_KEEPALIVE_INTERVAL", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionRetryAttempts, err := getEnvInt("SESSION_RETRY_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+	if sessionRetryAttempts < 1 {
+		return nil, fmt.Errorf("SESSION_RETRY_ATTEMPTS must be at least 1, got %d", sessionRetryAttempts)
+	}
+
+	sessionRetryBackoff, err := getEnvDuration("SESSION_RETRY_BACKOFF", 100*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionUserIsolation, err := getEnvBool("SESSION_USER_ISOLATION", true)
+	if err != nil {
+		return nil, err
+	}
+
+	var modelProxyURL *url.URL
+	if value := os.Getenv("MODEL_PROXY_URL"); value != "" {
+		modelProxyURL, err = url.Parse(value)
+		if err != nil || modelProxyURL.Host == "" {
+			return nil, fmt.Errorf("MODEL_PROXY_URL must be an absolute URL, got %q", value)
+		}
+		switch modelProxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("MODEL_PROXY_URL scheme must be http, https or socks5, got %q", modelProxyURL.Scheme)
+		}
+	}
+
+	modelRetryAttempts, err := getEnvInt("MODEL_RETRY_ATTEMPTS", 1)
+	if err != nil {
+		return nil, err
+	}
+	if modelRetryAttempts < 1 {
+		return nil, fmt.Errorf("MODEL_RETRY_ATTEMPTS must be at least 1, got %d", modelRetryAttempts)
+	}
+
+	modelRetryBackoff, err := getEnvDuration("MODEL_RETRY_BACKOFF", 500*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+
+	bufferResponse, err := getEnvBool("BUFFER_RESPONSE", false)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBlocklist := getEnvList("RESPONSE_BLOCKLIST")
+	if len(responseBlocklist) > 0 && !bufferResponse {
+		return nil, errors.New("RESPONSE_BLOCKLIST requires BUFFER_RESPONSE to be enabled")
+	}
+
+	// One pattern per line, since patterns may contain commas
+	var responseStripPatterns []*regexp.Regexp
+	for _, expr := range getEnvLines("RESPONSE_STRIP_PATTERNS") {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("RESPONSE_STRIP_PATTERNS must contain valid regular expressions, got %q: %w", expr, err)
+		}
+		responseStripPatterns = append(responseStripPatterns, pattern)
+	}
+	if len(responseStripPatterns) > 0 && !bufferResponse {
+		return nil, errors.New("RESPONSE_STRIP_PATTERNS requires BUFFER_RESPONSE to be enabled")
+	}
+
+	maxToolCalls, err := getEnvInt("MAX_TOOL_CALLS", 0)
+	if err != nil {
+		return nil, err
+	}
+	if maxToolCalls < 0 {
+		return nil, fmt.Errorf("MAX_TOOL_CALLS must not be negative, got %d", maxToolCalls)
+	}
+
+	imageMaxBytes, err := getEnvInt("IMAGE_MAX_BYTES", 10<<20)
+	if err != nil {
+		return nil, err
+	}
+	if imageMaxBytes < 1 {
+		return nil, fmt.Errorf("IMAGE_MAX_BYTES must be positive, got %d", imageMaxBytes)
+	}
+
+	imageFetchTimeout, err := getEnvDuration("IMAGE_FETCH_TIMEOUT", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	textChunking := os.Getenv("TEXT_CHUNKING")
+	if textChunking == "" {
+		textChunking = "token"
+	}
+	if textChunking != "token" && textChunking != "sentence" {
+		return nil, fmt.Errorf("TEXT_CHUNKING must be \"token\" or \"sentence\", got %q", textChunking)
+	}
+
+	emptyResponse := os.Getenv("EMPTY_RESPONSE")
+	if emptyResponse == "" {
+		emptyResponse = "fallback"
+	}
+	if emptyResponse != "fallback" && emptyResponse != "error" {
+		return nil, fmt.Errorf("EMPTY_RESPONSE must be \"fallback\" or \"error\", got %q", emptyResponse)
+	}
+
+	toolResultMessageMode := os.Getenv("TOOL_RESULT_MESSAGE_MODE")
+	if toolResultMessageMode == "" {
+		toolResultMessageMode = "separate"
+	}
+	if toolResultMessageMode != "separate" && toolResultMessageMode != "assistant" {
+		return nil, fmt.Errorf("TOOL_RESULT_MESSAGE_MODE must be \"separate\" or \"assistant\", got %q", toolResultMessageMode)
+	}
+
+	assistantRole := os.Getenv("ASSISTANT_ROLE")
+	if assistantRole == "" {
+		assistantRole = "assistant"
+	}
+	switch assistantRole {
+	case "assistant", "user", "system", "developer":
+	default:
+		return nil, fmt.Errorf("ASSISTANT_ROLE must be one of assistant, user, system, developer, got %q", assistantRole)
+	}
+
+	supportedLocales := getEnvList("SUPPORTED_LOCALES")
+	for _, locale := range supportedLocales {
+		if _, err := language.Parse(locale); err != nil {
+			return nil, fmt.Errorf("SUPPORTED_LOCALES contains invalid BCP-47 tag %q", locale)
+		}
+	}
+
+	defaultLocale := os.Getenv("DEFAULT_LOCALE")
+	if defaultLocale != "" && !slices.Contains(supportedLocales, defaultLocale) {
+		return nil, fmt.Errorf("DEFAULT_LOCALE %q must be one of SUPPORTED_LOCALES", defaultLocale)
+	}
+	if defaultLocale == "" && len(supportedLocales) > 0 {
+		defaultLocale = supportedLocales[0]
+	}
+
+	warmup, err := getEnvBool("WARMUP", false)
+	if err != nil {
+		return nil, err
+	}
+
+	warmupTimeout, err := getEnvDuration("WARMUP_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	threadTTL, err := getEnvDuration("THREAD_TTL", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	finalStateSnapshot, err := getEnvBool("FINAL_STATE_SNAPSHOT", false)
+	if err != nil {
+		return nil, err
+	}
+
+	streamStateDeltas, err := getEnvBool("STREAM_STATE_DELTAS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	logEvents, err := getEnvBool("LOG_EVENTS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	logEventBodies, err := getEnvBool("LOG_EVENT_BODIES", false)
+	if err != nil {
+		return nil, err
+	}
+
+	forwardSensitiveHeaders, err := getEnvBool("FORWARD_SENSITIVE_HEADERS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	var forwardHeaders []string
+	for _, name := range getEnvList("FORWARD_HEADERS") {
+		name = http.CanonicalHeaderKey(name)
+		if sensitiveHeaders[name] && !forwardSensitiveHeaders {
+			return nil, fmt.Errorf("FORWARD_HEADERS includes sensitive header %q; set FORWARD_SENSITIVE_HEADERS=true to allow it", name)
+		}
+		forwardHeaders = append(forwardHeaders, name)
+	}
+
+	forwardHeadersToProps, err := getEnvBool("FORWARD_HEADERS_TO_PROPS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	agentTimeout, err := getEnvDuration("AGENT_TIMEOUT", 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if agentTimeout <= 0 {
+		return nil, fmt.Errorf("AGENT_TIMEOUT must be positive, got %s", agentTimeout)
+	}
+
+	maxStreamDuration, err := getEnvDuration("MAX_STREAM_DURATION", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sseErrorAsHTTP, err := getEnvBool("SSE_ERROR_AS_HTTP", false)
+	if err != nil {
+		return nil, err
+	}
+
+	emitTyping, err := getEnvBool("EMIT_TYPING", false)
+	if err != nil {
+		return nil, err
+	}
+
+	moderationErrorCode := os.Getenv("MODERATION_ERROR_CODE")
+	if moderationErrorCode == "" {
+		moderationErrorCode = "moderation_rejected"
+	}
+
+	consumeAfterFinalResponse, err := getEnvBool("CONSUME_AFTER_FINAL_RESPONSE", false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configured agents list their own tools
+	enabledTools := getEnvList("ENABLED_TOOLS")
+	if len(enabledTools) > 0 && os.Getenv("AGENTS_CONFIG") != "" {
+		return nil, errors.New("ENABLED_TOOLS cannot be combined with AGENTS_CONFIG; list tools per agent there")
+	}
+
+	allowContinueRuns, err := getEnvBool("ALLOW_CONTINUE_RUNS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	planSegments, err := getEnvBool("PLAN_SEGMENTS", false)
+	if err != nil {
+		return nil, err
+	}
+	// Plan text is streamed as it arrives and would bypass the buffered post-processing
+	if planSegments && bufferResponse {
+		return nil, errors.New("PLAN_SEGMENTS cannot be combined with BUFFER_RESPONSE")
+	}
+
+	structuredToolEvents, err := getEnvBool("STRUCTURED_TOOL_EVENTS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	sseEventNames, err := getEnvBool("SSE_EVENT_NAMES", false)
+	if err != nil {
+		return nil, err
+	}
+
+	idempotencyTTL, err := getEnvDuration("IDEMPOTENCY_TTL", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultTemperature, err := getEnvFloat("DEFAULT_TEMPERATURE")
+	if err != nil {
+		return nil, err
+	}
+	if defaultTemperature != nil {
+		if err := ValidateTemperature(float64(*defaultTemperature)); err != nil {
+			return nil, fmt.Errorf("DEFAULT_TEMPERATURE: %w", err)
+		}
+	}
+
+	defaultTopP, err := getEnvFloat("DEFAULT_TOP_P")
+	if err != nil {
+		return nil, err
+	}
+	if defaultTopP != nil {
+		if err := ValidateTopP(float64(*defaultTopP)); err != nil {
+			return nil, fmt.Errorf("DEFAULT_TOP_P: %w", err)
+		}
+	}
+
+	stopSequences := getEnvList("STOP_SEQUENCES")
+	if err := ValidateStopSequences(stopSequences); err != nil {
+		return nil, fmt.Errorf("STOP_SEQUENCES: %w", err)
+	}
+
+	webhookTimeout, err := getEnvDuration("WEBHOOK_TIMEOUT", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookAttempts, err := getEnvInt("WEBHOOK_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+	if webhookAttempts < 1 {
+		return nil, fmt.Errorf("WEBHOOK_ATTEMPTS must be at least 1, got %d", webhookAttempts)
+	}
+
 	return &Config{
-		GoogleAPIKey: apiKey,
-		Port:         port,
-		AppName:      appName,
+		GoogleAPIKey:    apiKey,
+		Port:            port,
+		AppName:         appName,
+		AuthToken:       authToken,
+		AuthTokens:      authTokens,
+		EnablePprof:     enablePprof,
+		EnableSSE:       enableSSE,
+		EnableConnect:   enableConnect,
+		RequestIDHeader: requestIDHeader,
+		EnableThinking:  enableThinking,
+		SafetySettings:  safetySettings,
+		EnableRunFanOut: enableRunFanOut,
+		FanOutReplay:    fanOutReplay,
+
+		TrustedProxies:        trustedProxies,
+		ThreadNamespace:       threadNamespace,
+		ThreadNamespaceHeader: threadNamespaceHeader,
+
+		MaxConcurrentRuns:      maxConcurrentRuns,
+		MaxStateBytes:          maxStateBytes,
+		MaxConnections:         maxConnections,
+		MaxForwardedPropsBytes: maxForwardedPropsBytes,
+		StrictMessageFields:    strictMessageFields,
+		ConnectKeepAlive:       connectKeepAlive,
+
+		SessionRetryAttempts: sessionRetryAttempts,
+		SessionRetryBackoff:  sessionRetryBackoff,
+		SessionUserIsolation: sessionUserIsolation,
+
+		ModelProxyURL:      modelProxyURL,
+		ModelRetryAttempts: modelRetryAttempts,
+		ModelRetryBackoff:  modelRetryBackoff,
+
+		BufferResponse:    bufferResponse,
+		ResponseBlocklist: responseBlocklist,
+		TextChunking:      textChunking,
+		EmptyResponse:     emptyResponse,
+		AssistantRole:     assistantRole,
+		InitialGreeting:   os.Getenv("INITIAL_GREETING"),
+		MaxToolCalls:      maxToolCalls,
+		TranscriptPath:    transcriptPath,
+		AgentsConfigPath:  os.Getenv("AGENTS_CONFIG"),
+		EnabledTools:      enabledTools,
+		ImageMaxBytes:     int64(imageMaxBytes),
+		ImageFetchTimeout: imageFetchTimeout,
+
+		ResponseStripPatterns: responseStripPatterns,
+		ToolResultMessageMode: toolResultMessageMode,
+
+		SupportedLocales: supportedLocales,
+		DefaultLocale:    defaultLocale,
+
+		Warmup:        warmup,
+		WarmupTimeout: warmupTimeout,
+
+		ThreadTTL: threadTTL,
+
+		FinalStateSnapshot: finalStateSnapshot,
+		StreamStateDeltas:  streamStateDeltas,
+
+		LogEvents:      logEvents,
+		LogEventBodies: logEventBodies,
+
+		ForwardHeaders:        forwardHeaders,
+		ForwardHeadersToProps: forwardHeadersToProps,
+
+		AgentTimeout:      agentTimeout,
+		MaxStreamDuration: maxStreamDuration,
+		SSEErrorAsHTTP:    sseErrorAsHTTP,
+
+		EmitTyping:           emitTyping,
+		PlanSegments:         planSegments,
+		ModerationErrorCode:  moderationErrorCode,
+		StructuredToolEvents: structuredToolEvents,
+		SSEEventNames:        sseEventNames,
+		IdempotencyTTL:       idempotencyTTL,
+
+		ConsumeAfterFinalResponse: consumeAfterFinalResponse,
+		AllowContinueRuns:         allowContinueRuns,
+
+		DefaultTemperature: defaultTemperature,
+		DefaultTopP:        defaultTopP,
+		StopSequences:      stopSequences,
+
+		WebhookTimeout:  webhookTimeout,
+		WebhookAttempts: webhookAttempts,
 	}, nil
 }
+
+// parseAuthTokens parses "principal:token" entries into a token → principal map
+// Errors name the entry by position, so tokens never end up in logs
+func parseAuthTokens(entries []string) (map[string]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	tokens := make(map[string]string, len(entries))
+	for i, entry := range entries {
+		principal, token, ok := strings.Cut(entry, ":")
+		principal, token = strings.TrimSpace(principal), strings.TrimSpace(token)
+		if !ok || principal == "" || token == "" {
+			return nil, fmt.Errorf("AUTH_TOKENS entry %d must be principal:token", i)
+		}
+		if _, exists := tokens[token]; exists {
+			return nil, fmt.Errorf("AUTH_TOKENS entry %d reuses the token of another entry", i)
+		}
+		tokens[token] = principal
+	}
+	return tokens, nil
+}
+
+// getEnvBool reads a boolean environment variable, returning def when unset
+func getEnvBool(key string, def bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean, got %q", key, value)
+	}
+	return b, nil
+}
+
+// getEnvInt reads an integer environment variable, returning def when unset
+func getEnvInt(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer, got %q", key, value)
+	}
+	return n, nil
+}
+
+// getEnvFloat reads a float environment variable, returning nil when unset
+func getEnvFloat(key string) (*float32, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number, got %q", key, value)
+	}
+	f32 := float32(f)
+	return &f32, nil
+}
+
+// ValidateTemperature checks a sampling temperature is within the range the model accepts
+// NaN is rejected explicitly, as it compares false against both bounds
+func ValidateTemperature(temperature float64) error {
+	if math.IsNaN(temperature) || temperature < 0 || temperature > 2 {
+		return fmt.Errorf("temperature must be between 0 and 2, got %g", temperature)
+	}
+	return nil
+}
+
+// ValidateTopP checks a nucleus sampling probability is within the range the model accepts
+func ValidateTopP(topP float64) error {
+	if math.IsNaN(topP) || topP < 0 || topP > 1 {
+		return fmt.Errorf("topP must be between 0 and 1, got %g", topP)
+	}
+	return nil
+}
+
+// MaxStopSequences is the number of stop sequences the model accepts per request
+const MaxStopSequences = 5
+
+// ValidateStopSequences checks stop sequences are non-empty and within the model's limit
+func ValidateStopSequences(sequences []string) error {
+	if len(sequences) > MaxStopSequences {
+		return fmt.Errorf("at most %d stop sequences are allowed, got %d", MaxStopSequences, len(sequences))
+	}
+	for i, sequence := range sequences {
+		if sequence == "" {
+			return fmt.Errorf("stop sequence %d is empty", i)
+		}
+	}
+	return nil
+}
+
+// parsePrefix parses a CIDR, or a single IP address as a one-address prefix
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// getEnvList reads a comma-separated environment variable, skipping empty entries
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvLines reads a newline-separated environment variable, skipping blank lines
+func getEnvLines(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), "\n") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvDuration reads a duration environment variable (e.g. "500ms", "2s"), returning def when unset
+// Negative durations are rejected; every duration setting is a timeout, interval, backoff or TTL
+func getEnvDuration(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration, got %q", key, value)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative, got %s", key, d)
+	}
+	return d, nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDurations(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		wantErr string
+	}{
+		{"MODEL_RETRY_BACKOFF", "-1s", "MODEL_RETRY_BACKOFF must not be negative"},
+		{"SESSION_RETRY_BACKOFF", "-100ms", "SESSION_RETRY_BACKOFF must not be negative"},
+		{"WARMUP_TIMEOUT", "-5s", "WARMUP_TIMEOUT must not be negative"},
+		{"MAX_STREAM_DURATION", "-1m", "MAX_STREAM_DURATION must not be negative"},
+		{"WEBHOOK_TIMEOUT", "-1s", "WEBHOOK_TIMEOUT must not be negative"},
+		{"CONNECT_KEEPALIVE_INTERVAL", "-15s", "CONNECT_KEEPALIVE_INTERVAL must not be negative"},
+		{"AGENT_TIMEOUT", "-1s", "AGENT_TIMEOUT must not be negative"},
+		{"AGENT_TIMEOUT", "0", "AGENT_TIMEOUT must be positive"},
+		{"MAX_STREAM_DURATION", "soon", "MAX_STREAM_DURATION must be a duration"},
+		{"MODEL_RETRY_BACKOFF", "0", ""},
+		{"MAX_STREAM_DURATION", "0", ""},
+		{"CONNECT_KEEPALIVE_INTERVAL", "15s", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv("GOOGLE_API_KEY", "test-key")
+			t.Setenv(tt.key, tt.value)
+
+			_, err := Load()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadDefaultsOff(t *testing.T) {
+	t.Setenv("GOOGLE_API_KEY", "test-key")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// Features that reach out to client-supplied URLs or replay earlier runs are opt-in
+	for name, got := range map[string]time.Duration{
+		"IMAGE_FETCH_TIMEOUT": cfg.ImageFetchTimeout,
+		"IDEMPOTENCY_TTL":     cfg.IdempotencyTTL,
+		"WEBHOOK_TIMEOUT":     cfg.WebhookTimeout,
+	} {
+		if got != 0 {
+			t.Errorf("%s defaults to %s, want 0 (off)", name, got)
+		}
+	}
+}
+
+func TestLoadSamplingDefaults(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		wantErr string
+	}{
+		{"DEFAULT_TEMPERATURE", "NaN", "temperature must be between 0 and 2"},
+		{"DEFAULT_TEMPERATURE", "Inf", "temperature must be between 0 and 2"},
+		{"DEFAULT_TEMPERATURE", "-Inf", "temperature must be between 0 and 2"},
+		{"DEFAULT_TEMPERATURE", "2.5", "temperature must be between 0 and 2"},
+		{"DEFAULT_TEMPERATURE", "0.7", ""},
+		{"DEFAULT_TOP_P", "NaN", "topP must be between 0 and 1"},
+		{"DEFAULT_TOP_P", "+Inf", "topP must be between 0 and 1"},
+		{"DEFAULT_TOP_P", "-Inf", "topP must be between 0 and 1"},
+		{"DEFAULT_TOP_P", "1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv("GOOGLE_API_KEY", "test-key")
+			t.Setenv(tt.key, tt.value)
+
+			_, err := Load()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
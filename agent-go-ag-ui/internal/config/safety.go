@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// harmCategories are the safety categories supported by the Gemini API
+var harmCategories = map[string]genai.HarmCategory{
+	string(genai.HarmCategoryHarassment):       genai.HarmCategoryHarassment,
+	string(genai.HarmCategoryHateSpeech):       genai.HarmCategoryHateSpeech,
+	string(genai.HarmCategorySexuallyExplicit): genai.HarmCategorySexuallyExplicit,
+	string(genai.HarmCategoryDangerousContent): genai.HarmCategoryDangerousContent,
+	string(genai.HarmCategoryCivicIntegrity):   genai.HarmCategoryCivicIntegrity,
+}
+
+// harmBlockThresholds are the accepted safety thresholds
+var harmBlockThresholds = map[string]genai.HarmBlockThreshold{
+	string(genai.HarmBlockThresholdBlockLowAndAbove):    genai.HarmBlockThresholdBlockLowAndAbove,
+	string(genai.HarmBlockThresholdBlockMediumAndAbove): genai.HarmBlockThresholdBlockMediumAndAbove,
+	string(genai.HarmBlockThresholdBlockOnlyHigh):       genai.HarmBlockThresholdBlockOnlyHigh,
+	string(genai.HarmBlockThresholdBlockNone):           genai.HarmBlockThresholdBlockNone,
+	string(genai.HarmBlockThresholdOff):                 genai.HarmBlockThresholdOff,
+}
+
+// parseSafetySettings parses comma-separated CATEGORY=THRESHOLD pairs
+// e.g. "HARM_CATEGORY_HARASSMENT=BLOCK_ONLY_HIGH,HARM_CATEGORY_HATE_SPEECH=BLOCK_LOW_AND_ABOVE"
+// An empty value returns nil, leaving the model's default thresholds in place
+func parseSafetySettings(value string) ([]*genai.SafetySetting, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var settings []*genai.SafetySetting
+	seen := make(map[genai.HarmCategory]bool)
+	for _, pair := range strings.Split(value, ",") {
+		name, level, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("SAFETY_SETTINGS entry %q must be CATEGORY=THRESHOLD", pair)
+		}
+
+		category, ok := harmCategories[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("SAFETY_SETTINGS has unknown harm category %q", name)
+		}
+		threshold, ok := harmBlockThresholds[strings.TrimSpace(level)]
+		if !ok {
+			return nil, fmt.Errorf("SAFETY_SETTINGS has unknown threshold %q for %s", level, category)
+		}
+		if seen[category] {
+			return nil, fmt.Errorf("SAFETY_SETTINGS sets %s more than once", category)
+		}
+		seen[category] = true
+
+		settings = append(settings, &genai.SafetySetting{
+			Category:  category,
+			Threshold: threshold,
+		})
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+// metricsHandler serves gauges in the Prometheus text exposition format
+// State size gauges help spot threads whose state is never cleaned up before memory runs out
+func metricsHandler(stateMgr *transport.StateManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := stateMgr.Stats()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		writeGauge(w, "agui_state_threads", "Threads with stored state", stats.Threads)
+		writeGauge(w, "agui_state_bytes", "Approximate size of all stored thread state, measured as JSON bytes", stats.Bytes)
+	})
+}
+
+// writeGauge writes a single gauge with its HELP and TYPE lines
+func writeGauge(w http.ResponseWriter, name, help string, value int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
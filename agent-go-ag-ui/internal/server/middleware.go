@@ -0,0 +1,290 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+// loggingResponseWriter wraps http.ResponseWriter to capture status code
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func newLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
+	return &loggingResponseWriter{w, http.StatusOK}
+}
+
+func (lrw *loggingResponseWriter) WriteHeader(code int) {
+	lrw.statusCode = code
+	lrw.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher, which Connect requires for streaming responses
+func (lrw *loggingResponseWriter) Flush() {
+	if flusher, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer so http.ResponseController can flush streamed responses
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+// Logging logs HTTP requests
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lrw := newLoggingResponseWriter(w)
+		next.ServeHTTP(lrw, r)
+		log.Printf("[%s] %s %s %s %d %v", transport.RequestIDFromContext(r.Context()), transport.ClientIPFromContext(r.Context()), r.Method, r.URL.Path, lrw.statusCode, time.Since(start))
+	})
+}
+
+// CORS adds CORS headers to responses
+func CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Request-ID, "+transport.ProtocolVersionHeader+", "+transport.IdempotencyKeyHeader)
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, "+transport.ProtocolVersionHeader)
+		w.Header().Set("Access-Control-Max-Age", "3600")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestID reads the correlation ID from the given header (generating one if absent or invalid),
+// stores it in the request context and echoes it back in the response headers
+func RequestID(header string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(header)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(header, requestID)
+		next.ServeHTTP(w, r.WithContext(transport.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// validRequestID accepts short IDs of printable ASCII, so they are safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random 128-bit hex request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ForwardHeaders copies the named request headers into the request context so the agent's tools can read them
+// Header values are never logged
+func ForwardHeaders(names []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers := make(map[string]string, len(names))
+		for _, name := range names {
+			if value := r.Header.Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		if len(headers) > 0 {
+			r = r.WithContext(transport.WithForwardedHeaders(r.Context(), headers))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientIP stores the client's IP address in the request context
+// Forwarding headers are only believed when the immediate peer is a trusted proxy, so other
+// clients can't spoof their address
+func ClientIP(trustedProxies []netip.Prefix, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(transport.WithClientIP(r.Context(), clientIP(r, trustedProxies)))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address of the client behind any trusted proxies
+// X-Forwarded-For is read right to left, skipping trusted proxies; the first other address
+// is the client. Without X-Forwarded-For, X-Real-IP is used
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	addr := peer.Addr().Unmap()
+	if !isTrusted(addr, trustedProxies) {
+		return addr.String()
+	}
+
+	if forwardedFor := r.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				// An unparsable hop can't be attributed; stop at the last known address
+				break
+			}
+			addr = hop.Unmap()
+			if !isTrusted(addr, trustedProxies) {
+				break
+			}
+		}
+		return addr.String()
+	}
+	if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return realIP.Unmap().String()
+	}
+	return addr.String()
+}
+
+// isTrusted reports whether addr belongs to a trusted proxy
+func isTrusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ThreadNamespace stores the namespace of the request's thread IDs in the request context:
+// the value of header when set (e.g. a tenant ID added by an authenticating gateway), else namespace
+func ThreadNamespace(namespace, header string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestNamespace := namespace
+		if header != "" {
+			if value := r.Header.Get(header); value != "" {
+				requestNamespace = value
+			}
+		}
+		if requestNamespace != "" {
+			r = r.WithContext(transport.WithThreadNamespace(r.Context(), requestNamespace))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxIdempotencyKeyLength bounds client-supplied idempotency keys; longer keys are ignored
+const maxIdempotencyKeyLength = 256
+
+// IdempotencyKey stores the Idempotency-Key header in the request context
+func IdempotencyKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get(transport.IdempotencyKeyHeader); key != "" && len(key) <= maxIdempotencyKeyLength {
+			r = r.WithContext(transport.WithIdempotencyKey(r.Context(), key))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AcceptLanguage stores the request's Accept-Language header in the request context
+func AcceptLanguage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if acceptLanguage := r.Header.Get("Accept-Language"); acceptLanguage != "" {
+			r = r.WithContext(transport.WithAcceptLanguage(r.Context(), acceptLanguage))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ProtocolVersion negotiates the AG-UI version from the request header, rejecting
+// unsupported versions with a 400, and echoes the negotiated version back
+func ProtocolVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, err := transport.NegotiateProtocolVersion(r.Header.Get(transport.ProtocolVersionHeader))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(transport.ProtocolVersionHeader, version)
+		next.ServeHTTP(w, r.WithContext(transport.WithProtocolVersion(r.Context(), version)))
+	})
+}
+
+// SelectAgent stores the {agentName} path value in the request context to select the run's agent
+// With a prefix, "<prefix>/<agentName>" is stripped from the path, so the agent
+// segment can sit in front of routes that don't know about it (Connect procedures)
+func SelectAgent(prefix string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agentName := r.PathValue("agentName")
+		r = r.WithContext(transport.WithAgentName(r.Context(), agentName))
+		if prefix != "" {
+			http.StripPrefix(prefix+"/"+agentName, next).ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authenticate stores the caller's principal in the request context
+// With client tokens (token → principal), an "Authorization: Bearer <token>" header matching one
+// of them is required; without, every caller is transport.AnonymousPrincipal
+func Authenticate(tokens map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := transport.AnonymousPrincipal
+		if len(tokens) > 0 {
+			provided, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			var ok bool
+			if principal, ok = principalForToken(tokens, provided); !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(transport.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// principalForToken returns the principal of a client token
+// Every token is compared in constant time, so timing doesn't reveal which one nearly matched
+func principalForToken(tokens map[string]string, provided string) (string, bool) {
+	principal := ""
+	for token, owner := range tokens {
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			principal = owner
+		}
+	}
+	return principal, principal != "" && provided != ""
+}
+
+// Auth requires an "Authorization: Bearer <token>" header matching token
+func Auth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
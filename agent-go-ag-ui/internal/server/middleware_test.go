@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agent-go-ag-ui/internal/transport"
+)
+
+func TestAuthenticate(t *testing.T) {
+	tokens := map[string]string{"token-a": "alice", "token-b": "bob"}
+	tests := []struct {
+		name          string
+		tokens        map[string]string
+		authorization string
+		wantStatus    int
+		wantPrincipal string
+	}{
+		{"no tokens configured", nil, "", http.StatusOK, transport.AnonymousPrincipal},
+		{"first token", tokens, "Bearer token-a", http.StatusOK, "alice"},
+		{"second token", tokens, "Bearer token-b", http.StatusOK, "bob"},
+		{"missing header", tokens, "", http.StatusUnauthorized, ""},
+		{"unknown token", tokens, "Bearer token-c", http.StatusUnauthorized, ""},
+		{"empty bearer", tokens, "Bearer ", http.StatusUnauthorized, ""},
+		{"wrong scheme", tokens, "Basic token-a", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var principal string
+			handler := Authenticate(tt.tokens, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal = transport.PrincipalFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/sse", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if principal != tt.wantPrincipal {
+				t.Errorf("principal = %q, want %q", principal, tt.wantPrincipal)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/netutil"
+
+	"agent-go-ag-ui/gen/proto/agui/v1/aguiv1connect"
+	"agent-go-ag-ui/internal/config"
+	"agent-go-ag-ui/internal/transport"
+	"agent-go-ag-ui/internal/transport/connectrpc"
+	"agent-go-ag-ui/internal/transport/sse"
+)
+
+const (
+	// EndpointSSE is the endpoint for Server-Sent Events transport
+	EndpointSSE = "/sse"
+	// EndpointConnect is the endpoint for Connect RPC transport
+	EndpointConnect = "/connect"
+	// EndpointPprof is the prefix for the profiling endpoints
+	EndpointPprof = "/debug/pprof/"
+	// EndpointHealth reports that the process is up
+	EndpointHealth = "/healthz"
+	// EndpointReady reports whether the server is ready to take runs
+	EndpointReady = "/readyz"
+	// EndpointThreadState returns a thread's current state as JSON
+	EndpointThreadState = "/v1/threads/{threadId}/state"
+	// EndpointMetrics serves operational gauges in the Prometheus text format
+	EndpointMetrics = "/metrics"
+)
+
+// Server represents the HTTP server
+type Server struct {
+	httpServer     *http.Server
+	sseHandler     *sse.Handler
+	connectHandler *connectrpc.Handler
+	pprofEnabled   bool
+	// maxConnections caps concurrently open connections (0 = unlimited)
+	maxConnections int
+	// ready gates EndpointReady, e.g. until the model warmup succeeded
+	ready *atomic.Bool
+}
+
+// New creates a new server instance with multiple transport endpoints
+// A nil handler leaves its transport's endpoints unregistered
+// threads checks access to the thread state endpoint
+func New(cfg *config.Config, sseHandler *sse.Handler, connectHandler *connectrpc.Handler, stateMgr *transport.StateManager, threads ThreadAuthorizer) *Server {
+	mux := http.NewServeMux()
+
+	// SSE endpoint (explicit)
+	// The AG-UI endpoints negotiate the protocol version and run as the authenticated caller
+	if sseHandler != nil {
+		sse := Authenticate(cfg.AuthTokens, http.HandlerFunc(sseHandler.HandleAgentRequest))
+		mux.Handle(EndpointSSE, ProtocolVersion(sse))
+		// Agent selected by path, e.g. /sse/hello_time_agent
+		mux.Handle(EndpointSSE+"/{agentName}", ProtocolVersion(SelectAgent("", sse)))
+	}
+
+	// Connect RPC endpoint
+	if connectHandler != nil {
+		path, connectRPC := aguiv1connect.NewAGUIServiceHandler(connectHandler)
+		handler := Authenticate(cfg.AuthTokens, connectRPC)
+		mux.Handle(path, ProtocolVersion(handler))
+		// Also register explicit endpoint for convenience
+		mux.Handle(EndpointConnect, ProtocolVersion(handler))
+		// Agent selected by path, used as the client's base URL: /connect/{agentName}/agui.v1.AGUIService/...
+		mux.Handle(EndpointConnect+"/{agentName}/", ProtocolVersion(SelectAgent(EndpointConnect, handler)))
+	}
+
+	// Thread state, only with client authentication: without it every caller is the same
+	// anonymous principal and could read any thread
+	if len(cfg.AuthTokens) > 0 {
+		mux.Handle("GET "+EndpointThreadState, Authenticate(cfg.AuthTokens, threadStateHandler(stateMgr, threads)))
+	}
+
+	// Metrics, behind AUTH_TOKEN when one is set
+	var metrics http.Handler = metricsHandler(stateMgr)
+	if cfg.AuthToken != "" {
+		metrics = Auth(cfg.AuthToken, metrics)
+	}
+	mux.Handle("GET "+EndpointMetrics, metrics)
+
+	// Health endpoints
+	ready := &atomic.Bool{}
+	mux.HandleFunc(EndpointHealth, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc(EndpointReady, func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Profiling endpoints (opt-in, always behind auth)
+	if cfg.EnablePprof {
+		mux.Handle(EndpointPprof, Auth(cfg.AuthToken, pprofHandler()))
+	}
+
+	var handler http.Handler = IdempotencyKey(AcceptLanguage(mux))
+	if len(cfg.ForwardHeaders) > 0 {
+		handler = ForwardHeaders(cfg.ForwardHeaders, handler)
+	}
+	if cfg.ThreadNamespace != "" || cfg.ThreadNamespaceHeader != "" {
+		handler = ThreadNamespace(cfg.ThreadNamespace, cfg.ThreadNamespaceHeader, handler)
+	}
+
+	return &Server{
+		httpServer: &http.Server{
+			Addr:    ":" + cfg.Port,
+			Handler: CORS(RequestID(cfg.RequestIDHeader, ClientIP(cfg.TrustedProxies, Logging(handler)))),
+		},
+		sseHandler:     sseHandler,
+		connectHandler: connectHandler,
+		pprofEnabled:   cfg.EnablePprof,
+		maxConnections: cfg.MaxConnections,
+		ready:          ready,
+	}
+}
+
+// SetReady marks the server as ready (or not) on the readiness endpoint
+// A new server is not ready until SetReady(true) is called
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// pprofHandler serves the standard net/http/pprof handlers
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(EndpointPprof, pprof.Index)
+	mux.HandleFunc(EndpointPprof+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(EndpointPprof+"profile", pprof.Profile)
+	mux.HandleFunc(EndpointPprof+"symbol", pprof.Symbol)
+	mux.HandleFunc(EndpointPprof+"trace", pprof.Trace)
+	return mux
+}
+
+// Start starts the HTTP server
+func (s *Server) Start() error {
+	log.Printf("Starting AG-UI server on %s", s.httpServer.Addr)
+	if s.sseHandler != nil {
+		log.Printf("SSE endpoint: http://localhost%s%s", s.httpServer.Addr, EndpointSSE)
+	} else {
+		log.Printf("SSE endpoint: http://localhost%s%s (disabled)", s.httpServer.Addr, EndpointSSE)
+	}
+	if s.connectHandler != nil {
+		log.Printf("Connect RPC endpoint: http://localhost%s%s", s.httpServer.Addr, EndpointConnect)
+	} else {
+		log.Printf("Connect RPC endpoint: http://localhost%s%s (disabled)", s.httpServer.Addr, EndpointConnect)
+	}
+	if s.pprofEnabled {
+		log.Printf("pprof endpoint: http://localhost%s%s", s.httpServer.Addr, EndpointPprof)
+	}
+
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	// Connections beyond the cap wait to be accepted until one closes
+	if s.maxConnections > 0 {
+		listener = netutil.LimitListener(listener, s.maxConnections)
+	}
+	return s.httpServer.Serve(listener)
+}
+
+// Shutdown gracefully shuts down the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+// ShutdownTimeout shuts down the server with a default timeout
+func (s *Server) ShutdownTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
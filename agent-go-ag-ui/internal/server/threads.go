@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"agent-go-ag-ui/internal/agui_adapter"
+	"agent-go-ag-ui/internal/transport"
+)
+
+// ThreadAuthorizer decides whether the request's principal may access a thread outside of a run
+// threadID is the internal thread key (see transport.ThreadKey)
+type ThreadAuthorizer interface {
+	AuthorizeThread(ctx context.Context, threadID string) error
+}
+
+// threadStateHandler serves a thread's current merged state as plain JSON,
+// for clients that read state without opening a stream
+// The thread must pass the same ownership and Authorizer checks as a run on it
+// A denied thread answers 404 like an unknown one, so callers can't probe which thread IDs exist
+func threadStateHandler(stateMgr *transport.StateManager, threads ThreadAuthorizer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Same rules as a run's threadId, so a crafted ID can't name another namespace's key
+		if err := agui_adapter.ValidateID("threadId", r.PathValue("threadId")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		threadID := transport.ThreadKey(r.Context(), r.PathValue("threadId"))
+		if err := threads.AuthorizeThread(r.Context(), threadID); err != nil {
+			log.Printf("[%s] Thread state denied for %q: %v", transport.RequestIDFromContext(r.Context()), transport.PrincipalFromContext(r.Context()), err)
+			http.Error(w, "thread not found", http.StatusNotFound)
+			return
+		}
+		state, ok := stateMgr.Lookup(threadID)
+		if !ok {
+			http.Error(w, "thread not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if err := json.NewEncoder(w).Encode(state); err != nil {
+			log.Printf("[%s] Failed to write thread state: %v", transport.RequestIDFromContext(r.Context()), err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agent-go-ag-ui/internal/config"
+	"agent-go-ag-ui/internal/transport"
+)
+
+// ownerThreads lets each principal access only the threads listed for it
+type ownerThreads map[string]string
+
+func (o ownerThreads) AuthorizeThread(ctx context.Context, threadID string) error {
+	if o[threadID] != transport.PrincipalFromContext(ctx) {
+		return errors.New("not the thread's owner")
+	}
+	return nil
+}
+
+func TestThreadStateEndpoint(t *testing.T) {
+	stateMgr := transport.NewStateManager(0)
+	if err := stateMgr.Set("thread-1", map[string]interface{}{"count": 1}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	threads := ownerThreads{"thread-1": "alice", "thread-2": "alice"}
+	tokens := map[string]string{"token-a": "alice", "token-b": "bob"}
+
+	tests := []struct {
+		name       string
+		tokens     map[string]string
+		token      string
+		threadID   string
+		wantStatus int
+	}{
+		{"owner", tokens, "token-a", "thread-1", http.StatusOK},
+		{"owner, no state", tokens, "token-a", "thread-2", http.StatusNotFound},
+		{"other principal", tokens, "token-b", "thread-1", http.StatusNotFound},
+		{"unknown thread", tokens, "token-a", "thread-3", http.StatusNotFound},
+		{"no token", tokens, "", "thread-1", http.StatusUnauthorized},
+		{"not mounted without client tokens", nil, "", "thread-1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(&config.Config{AuthTokens: tt.tokens}, nil, nil, stateMgr, threads)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/threads/"+tt.threadID+"/state", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			srv.httpServer.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusNotFound && tt.tokens != nil && rec.Body.String() != "thread not found\n" {
+				t.Errorf("body = %q, want the same answer for every thread not found", rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(rec.Body.String(), `"count":1`) {
+				t.Errorf("body = %q, want the thread's state", rec.Body.String())
+			}
+		})
+	}
+}
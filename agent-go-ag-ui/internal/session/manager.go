@@ -2,53 +2,216 @@ package session
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"google.golang.org/adk/session"
 )
 
+// RetryPolicy controls how transient session backend errors are retried
+type RetryPolicy struct {
+	// Attempts is the total number of Get attempts (at least 1)
+	Attempts int
+	// Backoff is the delay before the first retry, doubled on each further retry
+	Backoff time.Duration
+}
+
+// ErrForbidden is returned when a user tries to resume a session owned by another user
+var ErrForbidden = errors.New("forbidden")
+
 // Manager manages agent sessions
 type Manager struct {
 	service session.Service
+	retry   RetryPolicy
+
+	// isolateUsers rejects access to a session ID first claimed by a different user
+	isolateUsers bool
+	mu           sync.Mutex
+	// owners maps appName/sessionID to the user that first used it
+	// Entries are removed with their session, on Delete or once idle for Cleanup
+	owners map[string]*sessionOwner
+}
+
+// sessionOwner is the user owning a session ID, and when a run last claimed it
+type sessionOwner struct {
+	appName   string
+	sessionID string
+	userID    string
+	lastUsed  time.Time
 }
 
 // NewManager creates a new session manager
-func NewManager() *Manager {
+// With isolateUsers, a session ID belongs to the first user that uses it
+func NewManager(retry RetryPolicy, isolateUsers bool) *Manager {
+	if retry.Attempts < 1 {
+		retry.Attempts = 1
+	}
 	return &Manager{
-		service: session.InMemoryService(),
+		service:      session.InMemoryService(),
+		retry:        retry,
+		isolateUsers: isolateUsers,
+		owners:       make(map[string]*sessionOwner),
+	}
+}
+
+// Claim records userID as the owner of a session ID, or returns ErrForbidden
+// if another user already owns it
+// Runs claim their thread before touching any of its data; GetOrCreate claims it too
+func (m *Manager) Claim(appName, userID, sessionID string) error {
+	if !m.isolateUsers {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := appName + "/" + sessionID
+	owner, exists := m.owners[key]
+	if exists && owner.userID != userID {
+		return ErrForbidden
+	}
+	if !exists {
+		owner = &sessionOwner{appName: appName, sessionID: sessionID, userID: userID}
+		m.owners[key] = owner
 	}
+	owner.lastUsed = time.Now()
+	return nil
+}
+
+// CheckOwner returns ErrForbidden unless userID may access a session ID without using it
+// With user isolation only the owner may, and a session ID nobody claimed yet is denied too
+func (m *Manager) CheckOwner(appName, userID, sessionID string) error {
+	if !m.isolateUsers {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if owner, exists := m.owners[appName+"/"+sessionID]; !exists || owner.userID != userID {
+		return ErrForbidden
+	}
+	return nil
 }
 
 // Create creates a new session
-func (m *Manager) Create(ctx context.Context, appName, userID string) (session.Session, error) {
+// If sessionID is empty the backend assigns one
+func (m *Manager) Create(ctx context.Context, appName, userID, sessionID string) (session.Session, error) {
 	sessResp, err := m.service.Create(ctx, &session.CreateRequest{
-		AppName: appName,
-		UserID:  userID,
+		AppName:   appName,
+		UserID:    userID,
+		SessionID: sessionID,
 	})
 	if err != nil {
 		var zeroSess session.Session
 		return zeroSess, fmt.Errorf("failed to create session: %w", err)
 	}
+	if sessResp == nil || sessResp.Session == nil {
+		return nil, errors.New("failed to create session: backend returned no session")
+	}
 
 	return sessResp.Session, nil
 }
 
 // GetOrCreate gets an existing session by ID or creates a new one
 // This allows reusing sessions for the same threadID
+// Transient backend errors are retried with backoff; a new session is only
+// created when the session genuinely doesn't exist
+// Returns ErrForbidden if user isolation is enabled and the session belongs to another user
 func (m *Manager) GetOrCreate(ctx context.Context, appName, userID, sessionID string) (session.Session, error) {
-	// Try to get existing session first
-	if sessionID != "" {
+	if sessionID == "" {
+		return m.Create(ctx, appName, userID, "")
+	}
+
+	if err := m.Claim(appName, userID, sessionID); err != nil {
+		return nil, err
+	}
+
+	backoff := m.retry.Backoff
+	var lastErr error
+	for attempt := 1; attempt <= m.retry.Attempts; attempt++ {
 		getResp, err := m.service.Get(ctx, &session.GetRequest{
+			AppName:   appName,
+			UserID:    userID,
 			SessionID: sessionID,
 		})
-		if err == nil && getResp != nil {
+		if err == nil && getResp != nil && getResp.Session != nil {
 			return getResp.Session, nil
 		}
-		// If get fails, fall through to create a new session
+		if err == nil || isNotFound(err) {
+			// Create a new session under the requested ID
+			// A nil response or nil session is treated as not found
+			return m.Create(ctx, appName, userID, sessionID)
+		}
+
+		lastErr = err
+		if attempt == m.retry.Attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to get session: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return nil, fmt.Errorf("failed to get session after %d attempts: %w", m.retry.Attempts, lastErr)
+}
+
+// Delete deletes a session and forgets its owner, so the session ID can be claimed again
+func (m *Manager) Delete(ctx context.Context, appName, userID, sessionID string) error {
+	m.mu.Lock()
+	if owner, exists := m.owners[appName+"/"+sessionID]; exists && owner.userID == userID {
+		delete(m.owners, appName+"/"+sessionID)
 	}
+	m.mu.Unlock()
+
+	if err := m.service.Delete(ctx, &session.DeleteRequest{
+		AppName:   appName,
+		UserID:    userID,
+		SessionID: sessionID,
+	}); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// Cleanup deletes the owned sessions no run claimed for longer than olderThan, with their owners
+// It expires sessions along with their thread state (THREAD_TTL), so ownership doesn't outlive them
+// Returns the number of sessions removed
+func (m *Manager) Cleanup(ctx context.Context, olderThan time.Duration) (int, error) {
+	m.mu.Lock()
+	now := time.Now()
+	var expired []*sessionOwner
+	for key, owner := range m.owners {
+		if now.Sub(owner.lastUsed) > olderThan {
+			delete(m.owners, key)
+			expired = append(expired, owner)
+		}
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, owner := range expired {
+		if err := m.service.Delete(ctx, &session.DeleteRequest{
+			AppName:   owner.appName,
+			UserID:    owner.userID,
+			SessionID: owner.sessionID,
+		}); err != nil && !isNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete session %s: %w", owner.sessionID, err))
+		}
+	}
+	return len(expired), errors.Join(errs...)
+}
 
-	// Create a new session if we don't have one or couldn't get it
-	return m.Create(ctx, appName, userID)
+// isNotFound reports whether a session service error means the session doesn't exist
+// ADK session services don't expose a sentinel error, so this matches on the message
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "not found")
 }
 
 // Service returns the underlying session service
@@ -0,0 +1,185 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/session"
+)
+
+func TestGetOrCreateUserIsolation(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(RetryPolicy{}, true)
+
+	if _, err := m.GetOrCreate(ctx, "app", "alice", "thread-1"); err != nil {
+		t.Fatalf("alice creating thread-1: %v", err)
+	}
+	if _, err := m.GetOrCreate(ctx, "app", "alice", "thread-1"); err != nil {
+		t.Fatalf("alice resuming thread-1: %v", err)
+	}
+	if _, err := m.GetOrCreate(ctx, "app", "bob", "thread-1"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("bob resuming thread-1: err = %v, want ErrForbidden", err)
+	}
+	if _, err := m.GetOrCreate(ctx, "app", "bob", "thread-2"); err != nil {
+		t.Fatalf("bob creating thread-2: %v", err)
+	}
+}
+
+func TestGetOrCreateWithoutIsolation(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(RetryPolicy{}, false)
+
+	if _, err := m.GetOrCreate(ctx, "app", "alice", "thread-1"); err != nil {
+		t.Fatalf("alice creating thread-1: %v", err)
+	}
+	if _, err := m.GetOrCreate(ctx, "app", "bob", "thread-1"); err != nil {
+		t.Fatalf("bob on thread-1 without isolation: %v", err)
+	}
+}
+
+func TestCheckOwner(t *testing.T) {
+	ctx := context.Background()
+	isolated := NewManager(RetryPolicy{}, true)
+	if _, err := isolated.GetOrCreate(ctx, "app", "alice", "thread-1"); err != nil {
+		t.Fatalf("alice creating thread-1: %v", err)
+	}
+	shared := NewManager(RetryPolicy{}, false)
+
+	tests := []struct {
+		name      string
+		m         *Manager
+		userID    string
+		sessionID string
+		wantErr   error
+	}{
+		{"owner", isolated, "alice", "thread-1", nil},
+		{"other user", isolated, "bob", "thread-1", ErrForbidden},
+		{"unclaimed thread", isolated, "alice", "thread-2", ErrForbidden},
+		{"without isolation", shared, "bob", "thread-1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.m.CheckOwner("app", tt.userID, tt.sessionID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckOwner = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	// Checking doesn't claim the thread
+	if _, err := isolated.GetOrCreate(ctx, "app", "bob", "thread-2"); err != nil {
+		t.Errorf("bob creating thread-2 after alice's check: %v", err)
+	}
+}
+
+// nilSessionService is a backend that answers Get, and optionally Create, without a session
+type nilSessionService struct {
+	session.Service
+	nilResponse bool
+	nilCreate   bool
+}
+
+func (s nilSessionService) Get(context.Context, *session.GetRequest) (*session.GetResponse, error) {
+	if s.nilResponse {
+		return nil, nil
+	}
+	return &session.GetResponse{}, nil
+}
+
+func (s nilSessionService) Create(ctx context.Context, req *session.CreateRequest) (*session.CreateResponse, error) {
+	if s.nilCreate {
+		return &session.CreateResponse{}, nil
+	}
+	return s.Service.Create(ctx, req)
+}
+
+func TestGetOrCreateNilSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		nilResponse bool
+		nilCreate   bool
+		wantErr     bool
+	}{
+		{name: "nil session is created"},
+		{name: "nil response is created", nilResponse: true},
+		{name: "create without session fails", nilCreate: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(RetryPolicy{}, true)
+			m.service = nilSessionService{Service: session.InMemoryService(), nilResponse: tt.nilResponse, nilCreate: tt.nilCreate}
+
+			sess, err := m.GetOrCreate(context.Background(), "app", "alice", "thread-1")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GetOrCreate = %v, want an error", sess)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetOrCreate: %v", err)
+			}
+			if sess == nil || sess.ID() != "thread-1" {
+				t.Fatalf("GetOrCreate = %v, want a session for thread-1", sess)
+			}
+		})
+	}
+}
+
+func TestDeleteForgetsOwner(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(RetryPolicy{}, true)
+	if _, err := m.GetOrCreate(ctx, "app", "alice", "thread-1"); err != nil {
+		t.Fatalf("alice creating thread-1: %v", err)
+	}
+
+	// Only the owner's delete forgets the owner
+	if err := m.Delete(ctx, "app", "bob", "thread-1"); err != nil {
+		t.Fatalf("bob deleting thread-1: %v", err)
+	}
+	if err := m.CheckOwner("app", "alice", "thread-1"); err != nil {
+		t.Fatalf("alice's ownership after bob's delete: %v", err)
+	}
+
+	if err := m.Delete(ctx, "app", "alice", "thread-1"); err != nil {
+		t.Fatalf("alice deleting thread-1: %v", err)
+	}
+	if len(m.owners) != 0 {
+		t.Errorf("owners = %v after delete, want none", m.owners)
+	}
+	if _, err := m.GetOrCreate(ctx, "app", "bob", "thread-1"); err != nil {
+		t.Errorf("bob creating the deleted thread-1: %v", err)
+	}
+}
+
+func TestCleanupForgetsIdleOwners(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(RetryPolicy{}, true)
+	for _, thread := range []string{"thread-1", "thread-2"} {
+		if _, err := m.GetOrCreate(ctx, "app", "alice", thread); err != nil {
+			t.Fatalf("alice creating %s: %v", thread, err)
+		}
+	}
+	m.owners["app/thread-1"].lastUsed = time.Now().Add(-2 * time.Hour)
+
+	removed, err := m.Cleanup(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("removed = %d, want 1", removed)
+	}
+	if _, exists := m.owners["app/thread-1"]; exists {
+		t.Error("idle thread-1 is still owned")
+	}
+	if err := m.CheckOwner("app", "alice", "thread-2"); err != nil {
+		t.Errorf("alice's ownership of the active thread-2: %v", err)
+	}
+
+	// The idle session went with its owner
+	getResp, err := m.Service().Get(ctx, &session.GetRequest{AppName: "app", UserID: "alice", SessionID: "thread-1"})
+	if err == nil && getResp != nil && getResp.Session != nil {
+		t.Error("idle session thread-1 was not deleted")
+	}
+}
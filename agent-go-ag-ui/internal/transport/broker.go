@@ -0,0 +1,205 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// ReplayMode controls which events a subscriber joining an in-progress run receives
+type ReplayMode string
+
+const (
+	// ReplayFromStart replays every event of the run before streaming live events
+	ReplayFromStart ReplayMode = "start"
+	// ReplayFromJoin only streams events published after the subscriber joined
+	ReplayFromJoin ReplayMode = "join"
+)
+
+// subscriberBuffer is the number of live events buffered per subscriber
+// A subscriber that falls further behind is dropped so it can't stall the run
+const subscriberBuffer = 256
+
+// ErrRunInProgress rejects a run whose run ID is still streaming on the same thread
+var ErrRunInProgress = errors.New("run is already in progress")
+
+// ErrorCodeRunInProgress is the RUN_ERROR code of a run rejected with ErrRunInProgress
+const ErrorCodeRunInProgress = "run_in_progress"
+
+// RunBroker fans out the events of in-progress runs to additional subscribers
+// Runs are keyed by their namespaced thread and run ID, so tenants reusing IDs stay apart
+type RunBroker struct {
+	mu     sync.Mutex
+	runs   map[string]*runTopic
+	replay ReplayMode
+}
+
+// runTopic holds the published events and live subscribers of a single run
+type runTopic struct {
+	// owner is the principal that started the run; only it may subscribe
+	owner       string
+	history     []events.Event
+	subscribers map[chan events.Event]struct{}
+}
+
+// NewRunBroker creates a new run broker
+func NewRunBroker(replay ReplayMode) *RunBroker {
+	return &RunBroker{
+		runs:   make(map[string]*runTopic),
+		replay: replay,
+	}
+}
+
+// runKey identifies a run: the request's namespaced thread (see ThreadKey) and the run ID
+func runKey(ctx context.Context, threadID, runID string) string {
+	return ThreadKey(ctx, threadID) + "\x00" + runID
+}
+
+// Open registers a run owned by owner so subscribers can attach to it
+// Returns ErrRunInProgress if a run with the same key is still open
+func (b *RunBroker) Open(key, owner string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.runs[key]; exists {
+		return ErrRunInProgress
+	}
+	b.runs[key] = &runTopic{owner: owner, subscribers: make(map[chan events.Event]struct{})}
+	return nil
+}
+
+// Publish delivers an event to all subscribers of a run without blocking
+func (b *RunBroker) Publish(key string, event events.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topic, exists := b.runs[key]
+	if !exists {
+		return
+	}
+
+	if b.replay == ReplayFromStart {
+		topic.history = append(topic.history, event)
+	}
+
+	for ch := range topic.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber, drop it rather than blocking the run
+			delete(topic.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends a run, closing all subscriber channels
+func (b *RunBroker) Close(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topic, exists := b.runs[key]
+	if !exists {
+		return
+	}
+
+	for ch := range topic.subscribers {
+		delete(topic.subscribers, ch)
+		close(ch)
+	}
+	delete(b.runs, key)
+}
+
+// Subscribe attaches the request's principal to an in-progress run on a thread of the request's namespace
+// Returns false if the run is unknown, already finished or started by another principal,
+// so other tenants' runs can't be told apart from missing ones
+// The returned function detaches the subscriber and must be called when done
+func (b *RunBroker) Subscribe(ctx context.Context, threadID, runID string) (<-chan events.Event, func(), bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topic, exists := b.runs[runKey(ctx, threadID, runID)]
+	if !exists || topic.owner != PrincipalFromContext(ctx) {
+		return nil, nil, false
+	}
+
+	// Size the buffer so the replayed history never blocks
+	ch := make(chan events.Event, len(topic.history)+subscriberBuffer)
+	for _, event := range topic.history {
+		ch <- event
+	}
+	topic.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, subscribed := topic.subscribers[ch]; subscribed {
+			delete(topic.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe, true
+}
+
+// eventSender mirrors agui_adapter.EventSender to avoid an import cycle
+type eventSender interface {
+	SendEvent(event events.Event) error
+	SendRunError(runID string, err error) error
+}
+
+// PublishingSender sends events through the wrapped sender and publishes them to the broker
+type PublishingSender struct {
+	sender eventSender
+	broker *RunBroker
+	// ctx is the request's context, naming its namespace and principal
+	ctx context.Context
+	// key is the run's broker key, set once the run was opened
+	key string
+}
+
+// Publishing wraps a sender so the events of the request's run are also published to subscribers
+// The run is owned by the request's principal
+func (b *RunBroker) Publishing(ctx context.Context, sender eventSender) *PublishingSender {
+	return &PublishingSender{
+		sender: sender,
+		broker: b,
+		ctx:    ctx,
+	}
+}
+
+// SendEvent sends the event and publishes it, opening the run on RUN_STARTED
+// A run ID still streaming on the same thread is rejected: the client gets a RUN_ERROR and the
+// returned ErrRunInProgress stops the run, leaving the other run's subscribers untouched
+func (p *PublishingSender) SendEvent(event events.Event) error {
+	if started, ok := event.(*events.RunStartedEvent); ok && p.key == "" {
+		key := runKey(p.ctx, started.ThreadID(), started.RunID())
+		if err := p.broker.Open(key, PrincipalFromContext(p.ctx)); err != nil {
+			p.sender.SendEvent(events.NewRunErrorEvent(err.Error(),
+				events.WithRunID(started.RunID()), events.WithErrorCode(ErrorCodeRunInProgress)))
+			return err
+		}
+		p.key = key
+	}
+
+	err := p.sender.SendEvent(event)
+	if p.key != "" {
+		p.broker.Publish(p.key, event)
+	}
+	return err
+}
+
+// SendRunError sends a RUN_ERROR event and publishes it
+func (p *PublishingSender) SendRunError(runID string, err error) error {
+	return p.SendEvent(events.NewRunErrorEvent(err.Error(), events.WithRunID(runID)))
+}
+
+// Close ends the published run, if one was started
+func (p *PublishingSender) Close() {
+	if p.key != "" {
+		p.broker.Close(p.key)
+	}
+}
@@ -0,0 +1,139 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// recordingSender records the events sent to the client
+type recordingSender struct {
+	events []events.Event
+}
+
+func (r *recordingSender) SendEvent(event events.Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingSender) SendRunError(runID string, err error) error {
+	return r.SendEvent(events.NewRunErrorEvent(err.Error(), events.WithRunID(runID)))
+}
+
+// principalCtx is a request context of principal in namespace
+func principalCtx(principal, namespace string) context.Context {
+	ctx := WithPrincipal(context.Background(), principal)
+	if namespace != "" {
+		ctx = WithThreadNamespace(ctx, namespace)
+	}
+	return ctx
+}
+
+// startRun publishes RUN_STARTED for threadID and runID as the request in ctx
+func startRun(t *testing.T, b *RunBroker, ctx context.Context, threadID, runID string) (*PublishingSender, *recordingSender, error) {
+	t.Helper()
+	client := &recordingSender{}
+	publisher := b.Publishing(ctx, client)
+	err := publisher.SendEvent(events.NewRunStartedEvent(threadID, runID))
+	return publisher, client, err
+}
+
+func TestRunBrokerKeysRunsByNamespaceAndThread(t *testing.T) {
+	b := NewRunBroker(ReplayFromStart)
+	alice := principalCtx("alice", "")
+	aliceTenant := principalCtx("alice", "tenant-b")
+
+	for _, run := range []struct {
+		ctx      context.Context
+		threadID string
+	}{
+		{alice, "thread-1"},
+		{alice, "thread-2"},
+		{aliceTenant, "thread-1"},
+	} {
+		if _, _, err := startRun(t, b, run.ctx, run.threadID, "run-1"); err != nil {
+			t.Fatalf("run-1 on %s: %v", run.threadID, err)
+		}
+	}
+
+	if _, _, ok := b.Subscribe(aliceTenant, "thread-2", "run-1"); ok {
+		t.Error("subscribed to a run of another namespace")
+	}
+	if _, unsubscribe, ok := b.Subscribe(aliceTenant, "thread-1", "run-1"); !ok {
+		t.Error("couldn't subscribe to the namespace's own run")
+	} else {
+		unsubscribe()
+	}
+}
+
+func TestRunBrokerRejectsDuplicateLiveRun(t *testing.T) {
+	b := NewRunBroker(ReplayFromStart)
+	ctx := principalCtx("alice", "")
+
+	first, _, err := startRun(t, b, ctx, "thread-1", "run-1")
+	if err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	eventChan, unsubscribe, ok := b.Subscribe(ctx, "thread-1", "run-1")
+	if !ok {
+		t.Fatal("couldn't subscribe to the first run")
+	}
+	defer unsubscribe()
+	<-eventChan // replayed RUN_STARTED
+
+	second, client, err := startRun(t, b, ctx, "thread-1", "run-1")
+	if !errors.Is(err, ErrRunInProgress) {
+		t.Fatalf("duplicate run error = %v, want ErrRunInProgress", err)
+	}
+	if len(client.events) != 1 {
+		t.Fatalf("duplicate run sent %d events, want 1", len(client.events))
+	}
+	runErr, isRunError := client.events[0].(*events.RunErrorEvent)
+	if !isRunError || runErr.Code == nil || *runErr.Code != ErrorCodeRunInProgress {
+		t.Errorf("duplicate run got %#v, want RUN_ERROR %q", client.events[0], ErrorCodeRunInProgress)
+	}
+
+	// Tearing down the rejected run must leave the first run's subscribers attached
+	second.Close()
+	if err := first.SendEvent(events.NewRunFinishedEvent("thread-1", "run-1")); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if event, open := <-eventChan; !open || event.Type() != events.EventTypeRunFinished {
+		t.Errorf("subscriber got %v (open %v), want RUN_FINISHED", event, open)
+	}
+
+	first.Close()
+	if _, _, err := startRun(t, b, ctx, "thread-1", "run-1"); err != nil {
+		t.Errorf("run ID not reusable after the run closed: %v", err)
+	}
+}
+
+func TestRunBrokerSubscribeRequiresOwner(t *testing.T) {
+	b := NewRunBroker(ReplayFromJoin)
+	if _, _, err := startRun(t, b, principalCtx("alice", ""), "thread-1", "run-1"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{"owner", principalCtx("alice", ""), true},
+		{"other principal", principalCtx("bob", ""), false},
+		{"no principal", context.Background(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, unsubscribe, ok := b.Subscribe(tt.ctx, "thread-1", "run-1")
+			if ok != tt.want {
+				t.Fatalf("Subscribe ok = %v, want %v", ok, tt.want)
+			}
+			if ok {
+				unsubscribe()
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package transport
+
+import "context"
+
+// clientIPKey is the context key for the client's IP address
+type clientIPKey struct{}
+
+// WithClientIP returns a context carrying the client's IP address
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext returns the client's IP address, or "" if none is set
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
@@ -3,8 +3,11 @@ package connectrpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	aguiv1 "agent-go-ag-ui/gen/proto/agui/v1"
 
@@ -12,28 +15,55 @@ import (
 	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
 	"google.golang.org/protobuf/types/known/structpb"
 
+	"agent-go-ag-ui/internal/agent"
 	"agent-go-ag-ui/internal/agui_adapter"
+	"agent-go-ag-ui/internal/config"
 	"agent-go-ag-ui/internal/transport"
 )
 
 // Handler handles Connect RPC requests for the AG-UI protocol
 // Only responsible for Protobuf serialization - protocol logic is in agui_adapter
 type Handler struct {
-	adapter  *agui_adapter.AGUIAdapter
-	stateMgr *transport.StateManager
+	adapter   *agui_adapter.AGUIAdapter
+	stateMgr  *transport.StateManager
+	broker    *transport.RunBroker
+	limiter   *transport.RunLimiter
+	keepAlive time.Duration
+	// maxForwardedPropsBytes caps the JSON size of forwardedProps (0 = unlimited)
+	maxForwardedPropsBytes int
+	// strictMessageFields rejects messages with unknown keys
+	strictMessageFields bool
+	// maxStreamDuration closes streams with RUN_ERROR after this long (0 = unlimited)
+	maxStreamDuration time.Duration
 }
 
 // NewHandler creates a new Connect RPC handler
-func NewHandler(adapter *agui_adapter.AGUIAdapter, stateMgr *transport.StateManager) *Handler {
+// broker is optional; when set, runs are published so SSE clients can subscribe
+// limiter is optional; when set, requests beyond its capacity fail with ResourceExhausted
+func NewHandler(cfg *config.Config, adapter *agui_adapter.AGUIAdapter, stateMgr *transport.StateManager, broker *transport.RunBroker, limiter *transport.RunLimiter) *Handler {
 	return &Handler{
-		adapter:  adapter,
-		stateMgr: stateMgr,
+		adapter:   adapter,
+		stateMgr:  stateMgr,
+		broker:    broker,
+		limiter:   limiter,
+		keepAlive: cfg.ConnectKeepAlive,
+
+		maxForwardedPropsBytes: cfg.MaxForwardedPropsBytes,
+		strictMessageFields:    cfg.StrictMessageFields,
+		maxStreamDuration:      cfg.MaxStreamDuration,
 	}
 }
 
+// EventTypeHeartbeat is the AGUIEvent type sent to keep idle streams alive
+// It is transport-level only and carries no data
+const EventTypeHeartbeat = "heartbeat"
+
 // connectEventSender implements agui_adapter.EventSender for Connect RPC transport
+// Sends are serialized so heartbeats can be interleaved from another goroutine
 type connectEventSender struct {
-	stream *connect.ServerStream[aguiv1.AGUIEvent]
+	mu       sync.Mutex
+	stream   *connect.ServerStream[aguiv1.AGUIEvent]
+	lastSend time.Time
 }
 
 func (c *connectEventSender) SendEvent(event events.Event) error {
@@ -41,9 +71,48 @@ func (c *connectEventSender) SendEvent(event events.Event) error {
 	if err != nil {
 		return fmt.Errorf("failed to convert event: %w", err)
 	}
+	return c.send(aguiEvent)
+}
+
+func (c *connectEventSender) send(aguiEvent *aguiv1.AGUIEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastSend = time.Now()
 	return c.stream.Send(aguiEvent)
 }
 
+// keepAlive sends a heartbeat whenever the stream has been idle for interval
+// A failed heartbeat means the client is gone: failed is called with the error and the heartbeats stop
+// Returns a function that stops the heartbeats
+func (c *connectEventSender) keepAlive(interval time.Duration, failed func(error)) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				c.mu.Lock()
+				idle := time.Since(c.lastSend)
+				c.mu.Unlock()
+				if idle < interval {
+					continue
+				}
+				if err := c.send(&aguiv1.AGUIEvent{Type: EventTypeHeartbeat}); err != nil {
+					failed(fmt.Errorf("failed to send heartbeat: %w", err))
+					return
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
 func (c *connectEventSender) SendRunError(runID string, err error) error {
 	errorEvent := events.NewRunErrorEvent(err.Error(), events.WithRunID(runID))
 	return c.SendEvent(errorEvent)
@@ -55,30 +124,90 @@ func (h *Handler) RunAgent(
 	req *aguiv1.RunAgentInput,
 	stream *connect.ServerStream[aguiv1.AGUIEvent],
 ) error {
+	// The stream is bounded by the duration cap, keepalives included
+	ctx, cancel := transport.WithStreamDeadline(ctx, h.maxStreamDuration)
+	defer cancel()
+	// A stream that can no longer be written to stops the run
+	ctx, cancelStream := context.WithCancelCause(ctx)
+	defer cancelStream(nil)
+
 	// Convert protobuf RunAgentInput to agui_adapter.RunAgentInput
-	runInput, err := h.convertRunAgentInput(req)
+	runInput, release, err := h.prepareRun(ctx, req)
 	if err != nil {
-		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("failed to convert request: %w", err))
-	}
-
-	// Validate input early (fail fast)
-	if err := runInput.Validate(); err != nil {
-		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("validation failed: %w", err))
+		return err
 	}
+	defer release()
 
 	// Create Connect RPC event sender
-	sender := &connectEventSender{stream: stream}
+	connectSender := &connectEventSender{stream: stream, lastSend: time.Now()}
+	if h.keepAlive > 0 {
+		stopKeepAlive := connectSender.keepAlive(h.keepAlive, cancelStream)
+		defer stopKeepAlive()
+	}
+	var sender agui_adapter.EventSender = connectSender
+	if h.broker != nil {
+		publisher := h.broker.Publishing(ctx, sender)
+		defer publisher.Close()
+		sender = publisher
+	}
 
 	// Delegate protocol logic to adapter
 	if err := h.adapter.RunAgentProtocol(ctx, runInput, h.stateMgr, sender); err != nil {
-		log.Printf("Error running agent protocol: %v", err)
+		log.Printf("[%s] Error running agent protocol: %v", transport.RequestIDFromContext(ctx), err)
 		// Error already sent via sender.SendRunError, but we need to return a Connect error
-		return connect.NewError(connect.CodeInternal, err)
+		return connect.NewError(runErrorCode(err), err)
 	}
 
 	return nil
 }
 
+// runErrorCode is the Connect code of a run that stopped with err
+func runErrorCode(err error) connect.Code {
+	if errors.Is(err, transport.ErrRunInProgress) {
+		return connect.CodeAlreadyExists
+	}
+	return connect.CodeInternal
+}
+
+// prepareRun converts and validates a request, then reserves a run slot
+// The returned function releases the slot and must be called when the run is done
+func (h *Handler) prepareRun(ctx context.Context, req *aguiv1.RunAgentInput) (*agui_adapter.RunAgentInput, func(), error) {
+	runInput, err := h.convertRunAgentInput(req)
+	if err != nil {
+		return nil, nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("failed to convert request: %w", err))
+	}
+
+	// Validate input early (fail fast)
+	if err := runInput.Validate(); err != nil {
+		return nil, nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("validation failed: %w", err))
+	}
+	if err := runInput.ValidateForwardedPropsSize(h.maxForwardedPropsBytes); err != nil {
+		return nil, nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("validation failed: %w", err))
+	}
+	if err := runInput.ValidateMessageFields(h.strictMessageFields); err != nil {
+		return nil, nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("validation failed: %w", err))
+	}
+
+	// Reject unknown agents before anything is sent
+	if _, err := h.adapter.SelectAgent(ctx, runInput); err != nil {
+		code := connect.CodeInvalidArgument
+		if errors.Is(err, agent.ErrUnknownAgent) {
+			code = connect.CodeNotFound
+		}
+		return nil, nil, connect.NewError(code, err)
+	}
+
+	// Reserve a run slot before anything is sent
+	if h.limiter != nil && runInput.HasMessages() && !runInput.StateSyncOnly() {
+		if !h.limiter.TryAcquire() {
+			return nil, nil, connect.NewError(connect.CodeResourceExhausted, errors.New("server is at run capacity, retry later"))
+		}
+		return runInput, h.limiter.Release, nil
+	}
+
+	return runInput, func() {}, nil
+}
+
 // convertRunAgentInput converts a protobuf RunAgentInput to agui_adapter.RunAgentInput
 func (h *Handler) convertRunAgentInput(req *aguiv1.RunAgentInput) (*agui_adapter.RunAgentInput, error) {
 	// Convert state
@@ -108,6 +237,9 @@ func (h *Handler) convertRunAgentInput(req *aguiv1.RunAgentInput) (*agui_adapter
 		if msg.ToolCalls != nil {
 			msgMap["tool_calls"] = msg.ToolCalls.AsInterface()
 		}
+		if msg.ToolCallId != "" {
+			msgMap["toolCallId"] = msg.ToolCallId
+		}
 		messages = append(messages, msgMap)
 	}
 
@@ -151,8 +283,8 @@ func (h *Handler) convertRunAgentInput(req *aguiv1.RunAgentInput) (*agui_adapter
 
 // convertAGUIEvent converts an AG-UI event to protobuf AGUIEvent
 func convertAGUIEvent(event events.Event) (*aguiv1.AGUIEvent, error) {
-	// Serialize event to JSON
-	eventJSON, err := json.Marshal(event)
+	// Serialize event to JSON, after replacing values (NaN, ±Inf, non-string keys) that would fail it
+	eventJSON, err := json.Marshal(sanitizeEvent(event))
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal event: %w", err)
 	}
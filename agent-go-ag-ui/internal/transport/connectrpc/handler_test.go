@@ -0,0 +1,174 @@
+package connectrpc
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	aguiv1 "agent-go-ag-ui/gen/proto/agui/v1"
+	"agent-go-ag-ui/gen/proto/agui/v1/aguiv1connect"
+
+	"connectrpc.com/connect"
+	"google.golang.org/adk/agent"
+	adksession "google.golang.org/adk/session"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"agent-go-ag-ui/internal/agui_adapter"
+	"agent-go-ag-ui/internal/config"
+	"agent-go-ag-ui/internal/session"
+	"agent-go-ag-ui/internal/transport"
+)
+
+// testConfig returns the configuration Load produces with no environment set
+func testConfig() *config.Config {
+	return &config.Config{
+		AppName:               "test-app",
+		AssistantRole:         "assistant",
+		AgentTimeout:          10 * time.Second,
+		ModelRetryAttempts:    1,
+		TextChunking:          "token",
+		EmptyResponse:         "fallback",
+		ToolResultMessageMode: "separate",
+		ImageMaxBytes:         10 << 20,
+		ModerationErrorCode:   "moderation_rejected",
+		SessionUserIsolation:  true,
+	}
+}
+
+// staticAgents resolves every agent name to the same agent
+type staticAgents struct {
+	agent agent.Agent
+}
+
+func (s staticAgents) Get(string) (agent.Agent, error) {
+	return s.agent, nil
+}
+
+// silentAgent is an agent that yields no events
+func silentAgent(t *testing.T) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "silent_agent",
+		Run: func(agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(func(*adksession.Event, error) bool) {}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+// newTestClient serves a Connect handler around a for the principal "alice" and returns a client for it
+func newTestClient(t *testing.T, a agent.Agent) aguiv1connect.AGUIServiceClient {
+	t.Helper()
+	return newTestClients(t, a, "alice")[0]
+}
+
+// newTestClients serves one Connect handler around a and returns a client for each principal
+func newTestClients(t *testing.T, a agent.Agent, principals ...string) []aguiv1connect.AGUIServiceClient {
+	t.Helper()
+	cfg := testConfig()
+	sessionMgr := session.NewManager(session.RetryPolicy{}, cfg.SessionUserIsolation)
+	adapter := agui_adapter.NewAGUIAdapter(cfg, staticAgents{a}, sessionMgr, nil, nil, nil, nil)
+	path, handler := aguiv1connect.NewAGUIServiceHandler(NewHandler(cfg, adapter, transport.NewStateManager(0), nil, nil))
+
+	var clients []aguiv1connect.AGUIServiceClient
+	for _, principal := range principals {
+		mux := http.NewServeMux()
+		mux.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler.ServeHTTP(w, r.WithContext(transport.WithPrincipal(r.Context(), principal)))
+		}))
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		clients = append(clients, aguiv1connect.NewAGUIServiceClient(server.Client(), server.URL))
+	}
+	return clients
+}
+
+// userRequest is a run request with a single user message
+func userRequest(threadID, text string) *aguiv1.RunAgentInput {
+	return &aguiv1.RunAgentInput{
+		ThreadId: threadID,
+		RunId:    "run-1",
+		Messages: []*aguiv1.Message{
+			{Id: "msg-1", Role: "user", Content: structpb.NewStringValue(text)},
+		},
+	}
+}
+
+// streamedText runs req as a stream and returns its TEXT_MESSAGE_CONTENT deltas and event types
+func streamedText(t *testing.T, client aguiv1connect.AGUIServiceClient, req *aguiv1.RunAgentInput) ([]string, []string) {
+	t.Helper()
+	stream, err := client.RunAgent(context.Background(), req)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	defer stream.Close()
+
+	var deltas, types []string
+	for stream.Receive() {
+		msg := stream.Msg()
+		types = append(types, msg.Type)
+		if msg.Type == "TEXT_MESSAGE_CONTENT" {
+			deltas = append(deltas, msg.Data.GetFields()["delta"].GetStringValue())
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	return deltas, types
+}
+
+func TestRunAgentEmptyResponseFallbackOnce(t *testing.T) {
+	client := newTestClient(t, silentAgent(t))
+
+	deltas, types := streamedText(t, client, userRequest("thread-1", "hello"))
+	if len(deltas) != 1 || deltas[0] == "" {
+		t.Fatalf("content deltas = %q, want exactly one fallback (events %v)", deltas, types)
+	}
+	if last := types[len(types)-1]; last != "RUN_FINISHED" {
+		t.Errorf("last event = %s, want RUN_FINISHED", last)
+	}
+
+	// The unary RPC drains the same protocol, so it carries the fallback once too
+	response, err := client.RunAgentUnary(context.Background(), userRequest("thread-2", "hello"))
+	if err != nil {
+		t.Fatalf("RunAgentUnary: %v", err)
+	}
+	if response.Text != deltas[0] {
+		t.Errorf("unary text = %q, want the fallback %q once", response.Text, deltas[0])
+	}
+}
+
+func TestRunAgentUnaryOnAnotherUsersThread(t *testing.T) {
+	clients := newTestClients(t, silentAgent(t), "alice", "bob")
+	alice, bob := clients[0], clients[1]
+
+	secret, err := structpb.NewStruct(map[string]interface{}{"secret": "alice"})
+	if err != nil {
+		t.Fatalf("NewStruct: %v", err)
+	}
+	req := userRequest("thread-1", "hello")
+	req.State = secret
+	if _, err := alice.RunAgentUnary(context.Background(), req); err != nil {
+		t.Fatalf("alice's run: %v", err)
+	}
+
+	// Bob is denied, and the response doesn't carry alice's state
+	response, err := bob.RunAgentUnary(context.Background(), &aguiv1.RunAgentInput{ThreadId: "thread-1", RunId: "run-2"})
+	if connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Fatalf("bob's run: response %v, err %v, want PermissionDenied", response, err)
+	}
+
+	response, err = alice.RunAgentUnary(context.Background(), &aguiv1.RunAgentInput{ThreadId: "thread-1", RunId: "run-3"})
+	if err != nil {
+		t.Fatalf("alice's state read: %v", err)
+	}
+	if got := response.State.GetFields()["secret"].GetStringValue(); got != "alice" {
+		t.Errorf("alice's state secret = %q, want alice", got)
+	}
+}
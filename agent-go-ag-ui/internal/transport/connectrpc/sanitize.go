@@ -0,0 +1,92 @@
+package connectrpc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+)
+
+// sanitizeEvent returns the event with free-form payloads made JSON/structpb-safe
+// Payload-carrying events are copied so the original (possibly shared with other senders) is untouched
+func sanitizeEvent(event events.Event) events.Event {
+	switch e := event.(type) {
+	case *events.CustomEvent:
+		sanitized := *e
+		sanitized.Value = sanitizeValue(e.Value)
+		return &sanitized
+	case *events.StateSnapshotEvent:
+		sanitized := *e
+		sanitized.Snapshot = sanitizeValue(e.Snapshot)
+		return &sanitized
+	case *events.StateDeltaEvent:
+		sanitized := *e
+		sanitized.Delta = make([]events.JSONPatchOperation, len(e.Delta))
+		for i, op := range e.Delta {
+			op.Value = sanitizeValue(op.Value)
+			sanitized.Delta[i] = op
+		}
+		return &sanitized
+	case *events.RawEvent:
+		sanitized := *e
+		sanitized.Event = sanitizeValue(e.Event)
+		return &sanitized
+	default:
+		return event
+	}
+}
+
+// sanitizeValue rewrites values that JSON and structpb can't represent:
+// NaN and ±Inf become null, and map keys are coerced to strings
+func sanitizeValue(v any) any {
+	switch value := v.(type) {
+	case nil, string, bool:
+		return value
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil
+		}
+		return value
+	case float32:
+		if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+			return nil
+		}
+		return value
+	case map[string]any:
+		result := make(map[string]any, len(value))
+		for k, item := range value {
+			result[k] = sanitizeValue(item)
+		}
+		return result
+	case []any:
+		result := make([]any, len(value))
+		for i, item := range value {
+			result[i] = sanitizeValue(item)
+		}
+		return result
+	}
+
+	// Other maps and slices are rebuilt generically; structs and scalars are left to encoding/json
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		result := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			result[fmt.Sprint(iter.Key().Interface())] = sanitizeValue(iter.Value().Interface())
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v // []byte encodes as base64
+		}
+		result := make([]any, rv.Len())
+		for i := range result {
+			result[i] = sanitizeValue(rv.Index(i).Interface())
+		}
+		return result
+	default:
+		return v
+	}
+}
@@ -0,0 +1,142 @@
+package connectrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	aguiv1 "agent-go-ag-ui/gen/proto/agui/v1"
+
+	"connectrpc.com/connect"
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"agent-go-ag-ui/internal/agui_adapter"
+	"agent-go-ag-ui/internal/transport"
+)
+
+// unaryCollector implements agui_adapter.EventSender by draining a run into a single response
+type unaryCollector struct {
+	response  *aguiv1.RunAgentResponse
+	text      strings.Builder
+	toolCalls map[string]*aguiv1.ToolCall
+	runError  *events.RunErrorEvent
+}
+
+// newUnaryCollector creates an empty collector
+func newUnaryCollector() *unaryCollector {
+	return &unaryCollector{
+		response:  &aguiv1.RunAgentResponse{},
+		toolCalls: make(map[string]*aguiv1.ToolCall),
+	}
+}
+
+func (c *unaryCollector) SendEvent(event events.Event) error {
+	switch e := event.(type) {
+	case *events.RunStartedEvent:
+		c.response.ThreadId = e.ThreadID()
+		c.response.RunId = e.RunID()
+	case *agui_adapter.SequencedTextMessageContentEvent:
+		c.text.WriteString(e.Delta)
+	case *events.TextMessageContentEvent:
+		c.text.WriteString(e.Delta)
+	case *events.ToolCallStartEvent:
+		toolCall := &aguiv1.ToolCall{Id: e.ToolCallID, Name: e.ToolCallName}
+		c.toolCalls[e.ToolCallID] = toolCall
+		c.response.ToolCalls = append(c.response.ToolCalls, toolCall)
+	case *events.ToolCallArgsEvent:
+		if toolCall, ok := c.toolCalls[e.ToolCallID]; ok {
+			toolCall.Arguments += e.Delta
+		}
+	case *events.ToolCallResultEvent:
+		if toolCall, ok := c.toolCalls[e.ToolCallID]; ok {
+			toolCall.Result = e.Content
+		}
+	case *agui_adapter.StructuredToolCallArgsEvent:
+		if toolCall, ok := c.toolCalls[e.ToolCallID]; ok {
+			toolCall.Arguments += e.Delta
+		}
+	case *agui_adapter.StructuredToolCallResultEvent:
+		if toolCall, ok := c.toolCalls[e.ToolCallID]; ok {
+			toolCall.Result = e.Content
+		}
+	case *events.RunFinishedEvent:
+		if result, ok := e.Result.(map[string]interface{}); ok {
+			c.response.FinishReason = fmt.Sprint(result["finishReason"])
+		}
+	case *events.RunErrorEvent:
+		c.runError = e
+	}
+	return nil
+}
+
+func (c *unaryCollector) SendRunError(runID string, err error) error {
+	return c.SendEvent(events.NewRunErrorEvent(err.Error(), events.WithRunID(runID)))
+}
+
+// err converts a collected RUN_ERROR into a Connect error
+func (c *unaryCollector) err() error {
+	if c.runError == nil {
+		return nil
+	}
+	code := connect.CodeInternal
+	if c.runError.Code != nil {
+		switch *c.runError.Code {
+		case "forbidden":
+			code = connect.CodePermissionDenied
+		case agui_adapter.ErrorCodeServerShutdown:
+			code = connect.CodeUnavailable
+		case agui_adapter.ErrorCodeCancelled:
+			code = connect.CodeCanceled
+		}
+	}
+	return connect.NewError(code, errors.New(c.runError.Message))
+}
+
+// RunAgentUnary implements the AGUIService.RunAgentUnary RPC method
+// It runs the same protocol as RunAgent, draining the events server-side into one response
+func (h *Handler) RunAgentUnary(
+	ctx context.Context,
+	req *aguiv1.RunAgentInput,
+) (*aguiv1.RunAgentResponse, error) {
+	runInput, release, err := h.prepareRun(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	collector := newUnaryCollector()
+	var sender agui_adapter.EventSender = collector
+	if h.broker != nil {
+		publisher := h.broker.Publishing(ctx, sender)
+		defer publisher.Close()
+		sender = publisher
+	}
+
+	if err := h.adapter.RunAgentProtocol(ctx, runInput, h.stateMgr, sender); err != nil {
+		log.Printf("[%s] Error running agent protocol: %v", transport.RequestIDFromContext(ctx), err)
+		return nil, connect.NewError(runErrorCode(err), err)
+	}
+	if err := collector.err(); err != nil {
+		return nil, err
+	}
+
+	response := collector.response
+	response.Text = collector.text.String()
+
+	// State-only requests have no RUN_STARTED, so take the thread from the request
+	if response.ThreadId == "" {
+		response.ThreadId = runInput.ThreadID
+	}
+	if response.ThreadId != "" {
+		state, err := structpb.NewStruct(sanitizeValue(h.stateMgr.Get(transport.ThreadKey(ctx, response.ThreadId))).(map[string]interface{}))
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to convert state: %w", err))
+		}
+		response.State = state
+	}
+
+	return response, nil
+}
@@ -0,0 +1,25 @@
+package transport
+
+import "context"
+
+// forwardedHeadersKey is the context key for headers forwarded to the agent and its tools
+type forwardedHeadersKey struct{}
+
+// WithForwardedHeaders returns a context carrying the forwarded request headers
+func WithForwardedHeaders(ctx context.Context, headers map[string]string) context.Context {
+	return context.WithValue(ctx, forwardedHeadersKey{}, headers)
+}
+
+// ForwardedHeadersFromContext returns a copy of the forwarded request headers, or nil if none are set
+// Tools receive the run context, so they can read the headers from it
+func ForwardedHeadersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(forwardedHeadersKey{}).(map[string]string)
+	if headers == nil {
+		return nil
+	}
+	result := make(map[string]string, len(headers))
+	for name, value := range headers {
+		result[name] = value
+	}
+	return result
+}
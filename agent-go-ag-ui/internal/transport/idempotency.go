@@ -0,0 +1,20 @@
+package transport
+
+import "context"
+
+// IdempotencyKeyHeader lets clients retry a run without running the model twice
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKeyKey is the context key for the request's idempotency key
+type idempotencyKeyKey struct{}
+
+// WithIdempotencyKey returns a context carrying the request's idempotency key
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the request's idempotency key, or "" if none is set
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	return key
+}
@@ -0,0 +1,29 @@
+package transport
+
+// RunLimiter caps the number of agent runs executing concurrently
+type RunLimiter struct {
+	slots chan struct{}
+}
+
+// NewRunLimiter creates a limiter allowing up to maxRuns concurrent runs
+func NewRunLimiter(maxRuns int) *RunLimiter {
+	return &RunLimiter{
+		slots: make(chan struct{}, maxRuns),
+	}
+}
+
+// TryAcquire takes a run slot without blocking
+// Returns false if the server is at capacity
+func (l *RunLimiter) TryAcquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release returns a slot taken with TryAcquire
+func (l *RunLimiter) Release() {
+	<-l.slots
+}
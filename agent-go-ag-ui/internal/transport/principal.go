@@ -0,0 +1,21 @@
+package transport
+
+import "context"
+
+// AnonymousPrincipal is the principal of every request when no client tokens are configured:
+// all callers are then the same user
+const AnonymousPrincipal = "anonymous"
+
+// principalKey is the context key for the authenticated caller
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated caller
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller, or "" if the request wasn't authenticated
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ProtocolVersionHeader is the request header selecting the AG-UI event contract,
+// echoed back in the response with the negotiated version
+const ProtocolVersionHeader = "AG-UI-Version"
+
+// SupportedProtocolVersions lists the AG-UI versions the server can emit, newest first
+var SupportedProtocolVersions = []string{"0.1"}
+
+// NegotiateProtocolVersion returns the version to emit for a requested one
+// An empty request gets the newest supported version
+func NegotiateProtocolVersion(requested string) (string, error) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return SupportedProtocolVersions[0], nil
+	}
+	if !slices.Contains(SupportedProtocolVersions, requested) {
+		return "", fmt.Errorf("unsupported %s %q (supported: %s)", ProtocolVersionHeader, requested, strings.Join(SupportedProtocolVersions, ", "))
+	}
+	return requested, nil
+}
+
+// protocolVersionKey is the context key for the negotiated AG-UI version
+type protocolVersionKey struct{}
+
+// WithProtocolVersion returns a context carrying the negotiated AG-UI version
+func WithProtocolVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, protocolVersionKey{}, version)
+}
+
+// ProtocolVersionFromContext returns the negotiated AG-UI version, or the newest supported one if none is set
+func ProtocolVersionFromContext(ctx context.Context) string {
+	if version, ok := ctx.Value(protocolVersionKey{}).(string); ok {
+		return version
+	}
+	return SupportedProtocolVersions[0]
+}
@@ -0,0 +1,17 @@
+package transport
+
+import "context"
+
+// requestIDKey is the context key for the request correlation ID
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the request correlation ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request correlation ID, or "" if none is set
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
@@ -0,0 +1,128 @@
+package transport
+
+import "context"
+
+// runInstructionsKey is the context key for per-run instructions appended to the agent's instruction
+type runInstructionsKey struct{}
+
+// WithRunInstruction returns a context carrying an extra instruction for this run only
+// Instructions accumulate, so several features can each add their own
+func WithRunInstruction(ctx context.Context, instruction string) context.Context {
+	existing := RunInstructionsFromContext(ctx)
+	instructions := make([]string, len(existing), len(existing)+1)
+	copy(instructions, existing)
+	return context.WithValue(ctx, runInstructionsKey{}, append(instructions, instruction))
+}
+
+// RunInstructionsFromContext returns the per-run instructions, or nil if none are set
+func RunInstructionsFromContext(ctx context.Context) []string {
+	instructions, _ := ctx.Value(runInstructionsKey{}).([]string)
+	return instructions
+}
+
+// acceptLanguageKey is the context key for the request's Accept-Language header
+type acceptLanguageKey struct{}
+
+// WithAcceptLanguage returns a context carrying the request's Accept-Language header
+func WithAcceptLanguage(ctx context.Context, acceptLanguage string) context.Context {
+	return context.WithValue(ctx, acceptLanguageKey{}, acceptLanguage)
+}
+
+// AcceptLanguageFromContext returns the request's Accept-Language header, or "" if none is set
+func AcceptLanguageFromContext(ctx context.Context) string {
+	acceptLanguage, _ := ctx.Value(acceptLanguageKey{}).(string)
+	return acceptLanguage
+}
+
+// agentNameKey is the context key for the agent selected by the request path
+type agentNameKey struct{}
+
+// WithAgentName returns a context carrying the agent selected by the request path
+func WithAgentName(ctx context.Context, agentName string) context.Context {
+	return context.WithValue(ctx, agentNameKey{}, agentName)
+}
+
+// AgentNameFromContext returns the agent selected by the request path, or "" if none is set
+func AgentNameFromContext(ctx context.Context) string {
+	agentName, _ := ctx.Value(agentNameKey{}).(string)
+	return agentName
+}
+
+// samplingKey is the context key for the run's sampling overrides
+type samplingKey struct{}
+
+// Sampling overrides the model's sampling parameters for one run (nil fields keep the agent's defaults)
+type Sampling struct {
+	Temperature *float32
+	TopP        *float32
+}
+
+// WithSampling returns a context carrying sampling overrides for this run
+func WithSampling(ctx context.Context, sampling Sampling) context.Context {
+	return context.WithValue(ctx, samplingKey{}, sampling)
+}
+
+// SamplingFromContext returns the run's sampling overrides, or an empty Sampling if none are set
+func SamplingFromContext(ctx context.Context) Sampling {
+	sampling, _ := ctx.Value(samplingKey{}).(Sampling)
+	return sampling
+}
+
+// stopSequencesKey is the context key for the run's stop sequences
+type stopSequencesKey struct{}
+
+// WithStopSequences returns a context carrying stop sequences that replace the agent's for this run
+func WithStopSequences(ctx context.Context, sequences []string) context.Context {
+	return context.WithValue(ctx, stopSequencesKey{}, sequences)
+}
+
+// StopSequencesFromContext returns the run's stop sequences; ok is false if none are set
+func StopSequencesFromContext(ctx context.Context) (sequences []string, ok bool) {
+	sequences, ok = ctx.Value(stopSequencesKey{}).([]string)
+	return sequences, ok
+}
+
+// responseFormatKey is the context key for the run's response format
+type responseFormatKey struct{}
+
+// ResponseFormat constrains the model's output for one run (the zero value is free text)
+type ResponseFormat struct {
+	// JSON requests a JSON response
+	JSON bool
+	// Schema is an optional JSON Schema the response must follow
+	Schema map[string]interface{}
+}
+
+// WithResponseFormat returns a context carrying the response format for this run
+func WithResponseFormat(ctx context.Context, format ResponseFormat) context.Context {
+	return context.WithValue(ctx, responseFormatKey{}, format)
+}
+
+// ResponseFormatFromContext returns the run's response format, or free text if none is set
+func ResponseFormatFromContext(ctx context.Context) ResponseFormat {
+	format, _ := ctx.Value(responseFormatKey{}).(ResponseFormat)
+	return format
+}
+
+// clientToolsKey is the context key for the tools the client declared for the run
+type clientToolsKey struct{}
+
+// ClientTool is a tool declared by the client (AG-UI "frontend tool"): the model may call it,
+// but the client executes it and sends the result back in a follow-up run
+type ClientTool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON Schema of the tool's arguments (nil = no arguments)
+	Parameters map[string]interface{}
+}
+
+// WithClientTools returns a context carrying the client's tools for this run
+func WithClientTools(ctx context.Context, tools []ClientTool) context.Context {
+	return context.WithValue(ctx, clientToolsKey{}, tools)
+}
+
+// ClientToolsFromContext returns the client's tools for the run, or nil if none are set
+func ClientToolsFromContext(ctx context.Context) []ClientTool {
+	tools, _ := ctx.Value(clientToolsKey{}).([]ClientTool)
+	return tools
+}
@@ -4,11 +4,16 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
+	"time"
 
+	"agent-go-ag-ui/internal/agent"
 	"agent-go-ag-ui/internal/agui_adapter"
+	"agent-go-ag-ui/internal/config"
 	"agent-go-ag-ui/internal/transport"
 
 	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
@@ -19,31 +24,165 @@ import (
 type Handler struct {
 	adapter  *agui_adapter.AGUIAdapter
 	stateMgr *transport.StateManager
+	broker   *transport.RunBroker
+	limiter  *transport.RunLimiter
+	// maxForwardedPropsBytes caps the JSON size of forwardedProps (0 = unlimited)
+	maxForwardedPropsBytes int
+	// strictMessageFields rejects messages with unknown keys
+	strictMessageFields bool
+	// errorAsHTTP answers runs that fail before anything was streamed with an HTTP error
+	errorAsHTTP bool
+	// eventNames writes each event's type as the SSE event name
+	eventNames bool
+	// maxStreamDuration closes streams with RUN_ERROR after this long (0 = unlimited)
+	maxStreamDuration time.Duration
 }
 
 // NewHandler creates a new SSE handler
-func NewHandler(adapter *agui_adapter.AGUIAdapter, stateMgr *transport.StateManager) *Handler {
+// broker is optional; when set, runs are published so other clients can subscribe
+// limiter is optional; when set, requests beyond its capacity get a 503
+func NewHandler(cfg *config.Config, adapter *agui_adapter.AGUIAdapter, stateMgr *transport.StateManager, broker *transport.RunBroker, limiter *transport.RunLimiter) *Handler {
 	return &Handler{
-		adapter:  adapter,
-		stateMgr: stateMgr,
+		adapter:                adapter,
+		stateMgr:               stateMgr,
+		broker:                 broker,
+		limiter:                limiter,
+		maxForwardedPropsBytes: cfg.MaxForwardedPropsBytes,
+		strictMessageFields:    cfg.StrictMessageFields,
+		errorAsHTTP:            cfg.SSEErrorAsHTTP,
+		eventNames:             cfg.SSEEventNames,
+		maxStreamDuration:      cfg.MaxStreamDuration,
 	}
 }
 
+// retryAfterSeconds is the Retry-After hint sent when the server is at run capacity
+const retryAfterSeconds = "1"
+
+// errRunFailedAsHTTP stops a sender after the run's failure was answered with an HTTP error
+var errRunFailedAsHTTP = errors.New("run failed before streaming started")
+
 // sseEventSender implements agui_adapter.EventSender for SSE transport
 type sseEventSender struct {
-	writer *bufio.Writer
+	w          *responseTracker
+	writer     *bufio.Writer
+	controller *http.ResponseController
+	// errorAsHTTP holds the run preamble back and answers a RUN_ERROR that arrives
+	// before anything reached the client with an HTTP error instead
+	errorAsHTTP bool
+	// eventNames adds an "event:" line with the AG-UI event type, for EventSource listeners
+	eventNames bool
+	// flushed is set once the response was flushed, committing the 200 status
+	flushed bool
+	// err is the first write failure; once set the client is gone and nothing more is written
+	err error
+}
+
+// responseTracker records whether anything was written to the response
+type responseTracker struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (t *responseTracker) Write(p []byte) (int, error) {
+	t.wrote = true
+	return t.ResponseWriter.Write(p)
+}
+
+// newSSEEventSender creates an SSE event sender writing to w
+func (h *Handler) newSSEEventSender(w http.ResponseWriter) *sseEventSender {
+	tracker := &responseTracker{ResponseWriter: w}
+	return &sseEventSender{
+		w:          tracker,
+		writer:     bufio.NewWriter(tracker),
+		controller: http.NewResponseController(w),
+		eventNames: h.eventNames,
+	}
 }
 
 func (s *sseEventSender) SendEvent(event events.Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	if runError, ok := event.(*events.RunErrorEvent); ok && s.canSendHTTPError() {
+		return s.sendHTTPError(runError)
+	}
+
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
-	_, err = fmt.Fprintf(s.writer, "data: %s\n\n", eventJSON)
-	if err != nil {
-		return err
+	if s.eventNames {
+		if _, err := fmt.Fprintf(s.writer, "event: %s\n", event.Type()); err != nil {
+			s.err = fmt.Errorf("failed to write event: %w", err)
+			return s.err
+		}
+	}
+	if _, err := fmt.Fprintf(s.writer, "data: %s\n\n", eventJSON); err != nil {
+		s.err = fmt.Errorf("failed to write event: %w", err)
+		return s.err
+	}
+	// The preamble is sent along with the next event, so an immediate failure can still be an HTTP error
+	if s.canSendHTTPError() && isPreamble(event) {
+		return nil
+	}
+	return s.flush()
+}
+
+// isPreamble reports whether an event only announces the run, before any of its output
+func isPreamble(event events.Event) bool {
+	switch e := event.(type) {
+	case *events.RunStartedEvent:
+		return true
+	case *events.CustomEvent:
+		return e.Name == agui_adapter.CustomEventRequestID
+	default:
+		return false
 	}
-	return s.writer.Flush()
+}
+
+// canSendHTTPError reports whether the response status can still be chosen
+func (s *sseEventSender) canSendHTTPError() bool {
+	return s.errorAsHTTP && !s.flushed && !s.w.wrote
+}
+
+// sendHTTPError discards the held-back preamble and answers with a JSON error
+// A forbidden run gets a 403, a run ID already in progress a 409, any other failure a 500
+func (s *sseEventSender) sendHTTPError(runError *events.RunErrorEvent) error {
+	s.writer.Reset(s.w)
+	status := http.StatusInternalServerError
+	body := map[string]string{"error": runError.Message}
+	if runError.Code != nil {
+		body["code"] = *runError.Code
+		switch *runError.Code {
+		case "forbidden":
+			status = http.StatusForbidden
+		case transport.ErrorCodeRunInProgress:
+			status = http.StatusConflict
+		}
+	}
+	s.w.Header().Set("Content-Type", "application/json")
+	s.w.WriteHeader(status)
+	if err := json.NewEncoder(s.w).Encode(body); err != nil {
+		s.err = fmt.Errorf("failed to write error response: %w", err)
+		return s.err
+	}
+	s.err = errRunFailedAsHTTP
+	return nil
+}
+
+// flush pushes buffered events to the client, through the bufio.Writer and the
+// http.ResponseWriter's own buffer
+func (s *sseEventSender) flush() error {
+	s.flushed = true
+	if err := s.writer.Flush(); err != nil {
+		s.err = fmt.Errorf("failed to flush event: %w", err)
+		return s.err
+	}
+	if err := s.controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.err = fmt.Errorf("failed to flush response: %w", err)
+		return s.err
+	}
+	return nil
 }
 
 func (s *sseEventSender) SendRunError(runID string, err error) error {
@@ -51,15 +190,29 @@ func (s *sseEventSender) SendRunError(runID string, err error) error {
 	return s.SendEvent(errorEvent)
 }
 
+// isJSONContent reports whether the request body is declared as JSON (parameters such as charset are allowed)
+func isJSONContent(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
 // HandleAgentRequest handles AG-UI protocol requests
+// Clients sending "Accept: text/plain" get the assistant text only, as a plain chunked stream
 func (h *Handler) HandleAgentRequest(w http.ResponseWriter, r *http.Request) {
+	plainText := wantsPlainText(r)
+
 	// Set headers for SSE
-	w.Header().Set("Content-Type", "text/event-stream")
+	if plainText {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Request-ID, "+transport.ProtocolVersionHeader+", "+transport.IdempotencyKeyHeader)
 
 	// Handle CORS preflight
 	if r.Method == "OPTIONS" {
@@ -67,16 +220,28 @@ func (h *Handler) HandleAgentRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Subscribe to an in-progress run
+	if r.Method == "GET" && h.broker != nil {
+		h.handleSubscribe(w, r)
+		return
+	}
+
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	// Only JSON bodies are accepted; anything else would fail decoding with a confusing error
+	if !isJSONContent(r) {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
 	// Parse request body
-	var input agui_adapter.RunAgentInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	input, err := agui_adapter.DecodeRunAgentInput(r.Body)
+	if err != nil {
 		log.Printf("Error decoding request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
 
@@ -86,23 +251,120 @@ func (h *Handler) HandleAgentRequest(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Validation failed: %v", err), http.StatusBadRequest)
 		return
 	}
+	if err := input.ValidateForwardedPropsSize(h.maxForwardedPropsBytes); err != nil {
+		log.Printf("Validation error: %v", err)
+		http.Error(w, fmt.Sprintf("Validation failed: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := input.ValidateMessageFields(h.strictMessageFields); err != nil {
+		log.Printf("Validation error: %v", err)
+		http.Error(w, fmt.Sprintf("Validation failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Reject unknown agents before streaming starts
+	if _, err := h.adapter.SelectAgent(r.Context(), input); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, agent.ErrUnknownAgent) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// Reserve a run slot before streaming starts, so over-capacity requests get a plain 503
+	// State-only requests (no messages, or stateSyncOnly) don't run the agent and skip the limit
+	if h.limiter != nil && input.HasMessages() && !input.StateSyncOnly() {
+		if !h.limiter.TryAcquire() {
+			w.Header().Set("Retry-After", retryAfterSeconds)
+			http.Error(w, "Server is at run capacity, retry later", http.StatusServiceUnavailable)
+			return
+		}
+		defer h.limiter.Release()
+	}
 
-	// Create context for agent execution
+	// Create context for agent execution, bounded by the stream duration cap
 	ctx := r.Context()
 	if ctx == nil {
 		ctx = context.Background()
 	}
-
-	// Create buffered writer for SSE
-	bufWriter := bufio.NewWriter(w)
+	ctx, cancel := transport.WithStreamDeadline(ctx, h.maxStreamDuration)
+	defer cancel()
 
 	// Create SSE event sender
-	sender := &sseEventSender{writer: bufWriter}
+	sseSender := h.newSSEEventSender(w)
+	sseSender.errorAsHTTP = h.errorAsHTTP
+	var sender agui_adapter.EventSender = sseSender
+	if plainText {
+		sender = &plainTextSender{sseSender}
+	}
+	if h.broker != nil {
+		publisher := h.broker.Publishing(ctx, sender)
+		defer publisher.Close()
+		sender = publisher
+	}
 
 	// Delegate protocol logic to adapter
-	if err := h.adapter.RunAgentProtocol(ctx, &input, h.stateMgr, sender); err != nil {
-		log.Printf("Error running agent protocol: %v", err)
+	if err := h.adapter.RunAgentProtocol(ctx, input, h.stateMgr, sender); err != nil {
+		if sseSender.err != nil && !errors.Is(sseSender.err, errRunFailedAsHTTP) {
+			// The client is gone, so there's nobody left to send a RUN_ERROR to
+			log.Printf("[%s] SSE client disconnected: %v", transport.RequestIDFromContext(ctx), err)
+			return
+		}
+		log.Printf("[%s] Error running agent protocol: %v", transport.RequestIDFromContext(ctx), err)
 		// Error already sent via sender.SendRunError
 		return
 	}
 }
+
+// handleSubscribe streams the events of an in-progress run to an additional client
+// The run is selected with the threadId and runId query parameters; like a run, the subscriber is
+// authenticated and authorized for the thread, and only the principal that started the run may attach
+func (h *Handler) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	threadID := r.URL.Query().Get("threadId")
+	runID := r.URL.Query().Get("runId")
+	if threadID == "" || runID == "" {
+		http.Error(w, "threadId and runId query parameters are required", http.StatusBadRequest)
+		return
+	}
+	for field, id := range map[string]string{"threadId": threadID, "runId": runID} {
+		if err := agui_adapter.ValidateID(field, id); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.adapter.AuthorizeThread(r.Context(), transport.ThreadKey(r.Context(), threadID)); err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	eventChan, unsubscribe, ok := h.broker.Subscribe(r.Context(), threadID, runID)
+	if !ok {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+	defer unsubscribe()
+
+	ctx, cancel := transport.WithStreamDeadline(r.Context(), h.maxStreamDuration)
+	defer cancel()
+
+	sender := h.newSSEEventSender(w)
+	for {
+		select {
+		case <-ctx.Done():
+			if transport.StreamDurationExceeded(ctx) {
+				sender.SendEvent(events.NewRunErrorEvent(transport.ErrStreamDurationExceeded.Error(),
+					events.WithRunID(runID), events.WithErrorCode(transport.ErrorCodeStreamDurationExceeded)))
+			}
+			return
+		case event, ok := <-eventChan:
+			if !ok {
+				return
+			}
+			if err := sender.SendEvent(event); err != nil {
+				log.Printf("Error streaming to subscriber of run %s: %v", runID, err)
+				return
+			}
+		}
+	}
+}
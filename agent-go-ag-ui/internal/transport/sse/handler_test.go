@@ -0,0 +1,278 @@
+package sse
+
+import (
+	"errors"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/adk/agent"
+	adksession "google.golang.org/adk/session"
+
+	"agent-go-ag-ui/internal/agui_adapter"
+	"agent-go-ag-ui/internal/config"
+	"agent-go-ag-ui/internal/session"
+	"agent-go-ag-ui/internal/transport"
+)
+
+// testConfig returns the configuration Load produces with no environment set
+func testConfig() *config.Config {
+	return &config.Config{
+		AppName:               "test-app",
+		AssistantRole:         "assistant",
+		AgentTimeout:          10 * time.Second,
+		ModelRetryAttempts:    1,
+		TextChunking:          "token",
+		EmptyResponse:         "fallback",
+		ToolResultMessageMode: "separate",
+		ImageMaxBytes:         10 << 20,
+		ModerationErrorCode:   "moderation_rejected",
+		SessionUserIsolation:  true,
+	}
+}
+
+// staticAgents resolves every agent name to the same agent
+type staticAgents struct {
+	agent agent.Agent
+}
+
+func (s staticAgents) Get(string) (agent.Agent, error) {
+	return s.agent, nil
+}
+
+// silentAgent is an agent that yields no events
+func silentAgent(t *testing.T) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "silent_agent",
+		Run: func(agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(func(*adksession.Event, error) bool) {}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+// newTestHandler creates an SSE handler around a silent agent
+func newTestHandler(t *testing.T, configure func(*config.Config)) *Handler {
+	t.Helper()
+	cfg := testConfig()
+	if configure != nil {
+		configure(cfg)
+	}
+	sessionMgr := session.NewManager(session.RetryPolicy{}, cfg.SessionUserIsolation)
+	adapter := agui_adapter.NewAGUIAdapter(cfg, staticAgents{silentAgent(t)}, sessionMgr, nil, nil, nil, nil)
+	return NewHandler(cfg, adapter, transport.NewStateManager(0), nil, nil)
+}
+
+// runRequest is a JSON POST of body by the principal "alice"
+func runRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r.WithContext(transport.WithPrincipal(r.Context(), "alice"))
+}
+
+// failingWriter is a ResponseWriter whose client is gone: every write fails
+// first holds the bytes of the first (failed) write
+type failingWriter struct {
+	header  http.Header
+	writes  int
+	flushes int
+	first   string
+}
+
+func (w *failingWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *failingWriter) WriteHeader(int) {}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes == 1 {
+		w.first = string(p)
+	}
+	return 0, errors.New("broken pipe")
+}
+
+func (w *failingWriter) Flush() {
+	w.flushes++
+}
+
+func TestHandleAgentRequestStopsAfterWriteFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		greeting  string
+		wantFirst string
+	}{
+		{name: "state snapshot", wantFirst: "STATE_SNAPSHOT"},
+		{name: "greeting", greeting: "Hello!", wantFirst: "RUN_STARTED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, func(cfg *config.Config) {
+				cfg.InitialGreeting = tt.greeting
+			})
+			body := `{"threadId":"thread-1","runId":"run-1","messages":[]}`
+			w := &failingWriter{}
+
+			h.HandleAgentRequest(w, runRequest(body))
+
+			if w.writes != 1 {
+				t.Errorf("writes = %d, want 1 (nothing after the first failure)", w.writes)
+			}
+			if w.flushes != 0 {
+				t.Errorf("flushes = %d, want 0 after the write failed", w.flushes)
+			}
+			if !strings.Contains(w.first, tt.wantFirst) {
+				t.Errorf("first write = %q, want a %s event", w.first, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestHandleAgentRequestRejectsOversizedForwardedProps(t *testing.T) {
+	h := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MaxForwardedPropsBytes = 64
+	})
+	body := `{"threadId":"thread-1","runId":"run-1","messages":[{"id":"msg-1","role":"user","content":"hi"}],` +
+		`"forwardedProps":{"notes":"` + strings.Repeat("x", 128) + `"}}`
+	w := httptest.NewRecorder()
+
+	h.HandleAgentRequest(w, runRequest(body))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "forwardedProps") {
+		t.Errorf("body = %q, want it to name forwardedProps", w.Body.String())
+	}
+}
+
+// blockingAgent is an agent that answers only once its run is cancelled, closing stopped when it is
+func blockingAgent(t *testing.T, stopped chan<- struct{}) agent.Agent {
+	t.Helper()
+	a, err := agent.New(agent.Config{
+		Name: "blocking_agent",
+		Run: func(ctx agent.InvocationContext) iter.Seq2[*adksession.Event, error] {
+			return func(yield func(*adksession.Event, error) bool) {
+				<-ctx.Done()
+				close(stopped)
+				yield(nil, ctx.Err())
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return a
+}
+
+// flushFailingWriter accepts writes but fails every flush after the first okFlushes,
+// like a connection reset mid-stream
+type flushFailingWriter struct {
+	header    http.Header
+	okFlushes int
+	writes    int
+	flushes   int
+}
+
+func (w *flushFailingWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *flushFailingWriter) WriteHeader(int) {}
+
+func (w *flushFailingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return len(p), nil
+}
+
+func (w *flushFailingWriter) FlushError() error {
+	w.flushes++
+	if w.flushes <= w.okFlushes {
+		return nil
+	}
+	return errors.New("connection reset")
+}
+
+func TestHandleAgentRequestStopsRunAfterFlushFailure(t *testing.T) {
+	stopped := make(chan struct{})
+	cfg := testConfig()
+	// The typing indicator is sent from inside the run, so its flush fails mid-run
+	cfg.EmitTyping = true
+	sessionMgr := session.NewManager(session.RetryPolicy{}, cfg.SessionUserIsolation)
+	adapter := agui_adapter.NewAGUIAdapter(cfg, staticAgents{blockingAgent(t, stopped)}, sessionMgr, nil, nil, nil, nil)
+	h := NewHandler(cfg, adapter, transport.NewStateManager(0), nil, nil)
+
+	body := `{"threadId":"thread-1","runId":"run-1","messages":[{"id":"msg-1","role":"user","content":"hi"}]}`
+	w := &flushFailingWriter{okFlushes: 1}
+
+	h.HandleAgentRequest(w, runRequest(body))
+
+	// The agent never answers on its own, so it only stops if the failed flush cancelled the run
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run kept going after the flush failed")
+	}
+
+	// RUN_STARTED goes through; the typing event's flush fails, and nothing follows it
+	if w.flushes != 2 {
+		t.Errorf("flushes = %d, want 2 (nothing after the first failure)", w.flushes)
+	}
+	if w.writes != 2 {
+		t.Errorf("writes = %d, want 2 (RUN_STARTED and the event whose flush failed)", w.writes)
+	}
+}
+
+func TestHandleAgentRequestContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		wantStatus  int
+	}{
+		{"application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"text/plain", http.StatusUnsupportedMediaType},
+		{"", http.StatusUnsupportedMediaType},
+		{"application/json; charset=utf-8", http.StatusOK},
+		{"application/json", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		name := tt.contentType
+		if name == "" {
+			name = "none"
+		}
+		t.Run(name, func(t *testing.T) {
+			h := newTestHandler(t, nil)
+			// An empty-messages request only returns the state, so the agent isn't run
+			r := runRequest(`{"threadId":"thread-1","messages":[]}`)
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			h.HandleAgentRequest(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusUnsupportedMediaType && !strings.Contains(w.Body.String(), "application/json") {
+				t.Errorf("body = %q, want it to name application/json", w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(w.Body.String(), "STATE_SNAPSHOT") {
+				t.Errorf("body = %q, want the state snapshot", w.Body.String())
+			}
+		})
+	}
+}
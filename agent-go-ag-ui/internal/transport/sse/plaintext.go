@@ -0,0 +1,68 @@
+package sse
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ag-ui-protocol/ag-ui/sdks/community/go/pkg/core/events"
+
+	"agent-go-ag-ui/internal/agui_adapter"
+)
+
+// plainTextSender streams only the assistant text, with no event envelope, for clients
+// (or proxies) that can't handle SSE; tool calls, state and thinking are dropped
+// It shares the SSE sender's buffered writer, flushing and write-failure tracking
+type plainTextSender struct {
+	*sseEventSender
+}
+
+func (p *plainTextSender) SendEvent(event events.Event) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	var text string
+	switch e := event.(type) {
+	case *agui_adapter.SequencedTextMessageContentEvent:
+		text = e.Delta
+	case *events.TextMessageContentEvent:
+		text = e.Delta
+	case *events.RunErrorEvent:
+		if p.canSendHTTPError() {
+			return p.sendHTTPError(e)
+		}
+		// The status is already 200, so failures are reported inline
+		text = fmt.Sprintf("\n\n[error] %s\n", e.Message)
+	default:
+		return nil
+	}
+
+	if _, err := p.writer.WriteString(text); err != nil {
+		p.err = fmt.Errorf("failed to write text: %w", err)
+		return p.err
+	}
+	return p.flush()
+}
+
+func (p *plainTextSender) SendRunError(runID string, err error) error {
+	return p.SendEvent(events.NewRunErrorEvent(err.Error(), events.WithRunID(runID)))
+}
+
+// wantsPlainText reports whether the client asked for raw text (Accept: text/plain) rather than SSE
+func wantsPlainText(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/plain":
+			return true
+		case "text/event-stream", "*/*":
+			return false
+		}
+	}
+	return false
+}
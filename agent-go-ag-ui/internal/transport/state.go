@@ -1,31 +1,97 @@
 package transport
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"sync"
 	"time"
 )
 
+// ErrStateTooLarge is returned when a thread's state would exceed the configured size cap
+var ErrStateTooLarge = errors.New("state too large")
+
+// ErrInvalidState is returned for state that can't be stored as JSON (e.g. nested too deeply)
+var ErrInvalidState = errors.New("invalid state")
+
+// maxStateDepth bounds the nesting of objects and arrays in a thread's state
+const maxStateDepth = 32
+
 // StateManager manages state persistence per threadId
 type StateManager struct {
 	mu     sync.RWMutex
 	states map[string]map[string]interface{}
 	// Optional: track last access time for cleanup
 	lastAccess map[string]time.Time
+	// maxBytes caps the JSON size of a thread's state (0 = unlimited)
+	maxBytes int
+	// sizes holds the JSON size of each thread's state; totalBytes is their sum
+	sizes      map[string]int
+	totalBytes int
+}
+
+// StateStats reports how much state a StateManager tracks, for monitoring memory
+type StateStats struct {
+	// Threads is the number of threads with stored state
+	Threads int
+	// Bytes is the approximate memory held by that state, measured as its JSON size
+	Bytes int
 }
 
 // NewStateManager creates a new state manager
-func NewStateManager() *StateManager {
+// maxBytes caps the JSON-encoded size of each thread's state (0 = unlimited)
+func NewStateManager(maxBytes int) *StateManager {
 	return &StateManager{
 		states:     make(map[string]map[string]interface{}),
 		lastAccess: make(map[string]time.Time),
+		maxBytes:   maxBytes,
+		sizes:      make(map[string]int),
 	}
 }
 
-// Get retrieves state for a threadId
-func (m *StateManager) Get(threadID string) map[string]interface{} {
+// checkSize returns the size of the JSON encoding of state, or ErrStateTooLarge if it exceeds the cap
+func (m *StateManager) checkSize(state map[string]interface{}) (int, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return 0, fmt.Errorf("state is not JSON-serializable: %w", err)
+	}
+	if m.maxBytes > 0 && len(data) > m.maxBytes {
+		return 0, fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrStateTooLarge, len(data), m.maxBytes)
+	}
+	return len(data), nil
+}
+
+// store records a thread's state and its size; callers must hold the write lock
+func (m *StateManager) store(threadID string, state map[string]interface{}, size int) {
+	m.states[threadID] = state
+	m.lastAccess[threadID] = time.Now()
+	m.totalBytes += size - m.sizes[threadID]
+	m.sizes[threadID] = size
+}
+
+// remove forgets a thread's state; callers must hold the write lock
+func (m *StateManager) remove(threadID string) {
+	delete(m.states, threadID)
+	delete(m.lastAccess, threadID)
+	m.totalBytes -= m.sizes[threadID]
+	delete(m.sizes, threadID)
+}
+
+// Stats returns the number of tracked threads and the approximate size of their state
+func (m *StateManager) Stats() StateStats {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	return StateStats{Threads: len(m.states), Bytes: m.totalBytes}
+}
+
+// Get retrieves state for a threadId
+// It takes the write lock because it records the access time
+func (m *StateManager) Get(threadID string) map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	state, exists := m.states[threadID]
 	if !exists {
 		return make(map[string]interface{})
@@ -35,38 +101,59 @@ func (m *StateManager) Get(threadID string) map[string]interface{} {
 	m.lastAccess[threadID] = time.Now()
 
 	// Return a copy to prevent external modifications
-	result := make(map[string]interface{})
-	for k, v := range state {
-		result[k] = v
-	}
-	return result
+	return copyState(state)
 }
 
-// Set sets state for a threadId (replaces existing state)
-func (m *StateManager) Set(threadID string, state map[string]interface{}) {
+// Lookup retrieves state for a threadId, reporting whether the thread is known
+func (m *StateManager) Lookup(threadID string) (map[string]interface{}, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	state, exists := m.states[threadID]
+	if !exists {
+		return nil, false
+	}
+	m.lastAccess[threadID] = time.Now()
+	return copyState(state), true
+}
+
+// Set sets state for a threadId (replaces existing state)
+// Returns ErrStateTooLarge, leaving the stored state unchanged, if state exceeds the size cap
+func (m *StateManager) Set(threadID string, state map[string]interface{}) error {
 	if state == nil {
 		state = make(map[string]interface{})
 	}
-
-	// Store a copy to prevent external modifications
-	result := make(map[string]interface{})
-	for k, v := range state {
-		result[k] = v
+	size, err := m.checkSize(state)
+	if err != nil {
+		return err
 	}
 
-	m.states[threadID] = result
-	m.lastAccess[threadID] = time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Store a copy to prevent external modifications
+	m.store(threadID, copyState(state), size)
+	return nil
 }
 
+// StateResetKey is a reserved incoming state key listing keys to remove from the thread state
+// e.g. {"__reset": ["draft", "filters"], "page": 2} removes draft and filters, then sets page
+const StateResetKey = "__reset"
+
 // Merge merges incoming state with existing state for a threadId
 // Incoming state takes precedence for overlapping keys
-func (m *StateManager) Merge(threadID string, incomingState map[string]interface{}) map[string]interface{} {
+// Keys listed under StateResetKey are removed before the overlay; the removed keys
+// that were actually present are returned so callers can emit a STATE_DELTA
+// Returns ErrStateTooLarge, leaving the stored state unchanged, if the merged state exceeds the size cap
+func (m *StateManager) Merge(threadID string, incomingState map[string]interface{}) (map[string]interface{}, []string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	incomingState, err := NormalizeState(incomingState)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	existing, exists := m.states[threadID]
 	if !exists {
 		existing = make(map[string]interface{})
@@ -75,25 +162,43 @@ func (m *StateManager) Merge(threadID string, incomingState map[string]interface
 	// Merge states - incoming state takes precedence
 	merged := make(map[string]interface{})
 
-	// First, copy existing state
+	// First, copy existing state (values are never mutated in place, so sharing them is safe)
 	for k, v := range existing {
 		merged[k] = v
 	}
 
-	// Then, overlay incoming state
+	// Then, remove reset keys
+	var removed []string
+	if resetKeys, ok := incomingState[StateResetKey].([]interface{}); ok {
+		for _, key := range resetKeys {
+			k, ok := key.(string)
+			if !ok {
+				continue
+			}
+			if _, present := merged[k]; present {
+				delete(merged, k)
+				removed = append(removed, k)
+			}
+		}
+	}
+
+	// Then, overlay incoming state (already a fresh copy, so the caller's maps aren't retained)
 	for k, v := range incomingState {
+		if k == StateResetKey {
+			continue
+		}
 		merged[k] = v
 	}
 
-	m.states[threadID] = merged
-	m.lastAccess[threadID] = time.Now()
+	size, err := m.checkSize(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.store(threadID, merged, size)
 
 	// Return a copy
-	result := make(map[string]interface{})
-	for k, v := range merged {
-		result[k] = v
-	}
-	return result
+	return copyState(merged), removed, nil
 }
 
 // Delete removes state for a threadId
@@ -101,8 +206,7 @@ func (m *StateManager) Delete(threadID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	delete(m.states, threadID)
-	delete(m.lastAccess, threadID)
+	m.remove(threadID)
 }
 
 // Cleanup removes states older than the specified duration
@@ -116,8 +220,7 @@ func (m *StateManager) Cleanup(olderThan time.Duration) int {
 
 	for threadID, lastAccess := range m.lastAccess {
 		if now.Sub(lastAccess) > olderThan {
-			delete(m.states, threadID)
-			delete(m.lastAccess, threadID)
+			m.remove(threadID)
 			removed++
 		}
 	}
@@ -125,3 +228,98 @@ func (m *StateManager) Cleanup(olderThan time.Duration) int {
 	return removed
 }
 
+// NormalizeState returns a JSON-safe deep copy of state, so storing and emitting it never fails:
+// NaN and ±Inf become null, and other Go values (e.g. structs set by tools) are converted through JSON
+// Returns ErrInvalidState for state nested deeper than maxStateDepth or not representable as JSON
+func NormalizeState(state map[string]interface{}) (map[string]interface{}, error) {
+	normalized, err := normalizeValue(state, 0)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := normalized.(map[string]interface{})
+	return result, nil
+}
+
+// normalizeValue normalizes a state value found at the given nesting depth
+func normalizeValue(v interface{}, depth int) (interface{}, error) {
+	switch value := v.(type) {
+	case nil, bool, string, json.Number,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return value, nil
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, nil
+		}
+		return value, nil
+	case float32:
+		return normalizeValue(float64(value), depth)
+	case map[string]interface{}:
+		if depth >= maxStateDepth {
+			return nil, fmt.Errorf("%w: nested deeper than %d levels", ErrInvalidState, maxStateDepth)
+		}
+		if value == nil {
+			return nil, nil
+		}
+		result := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			normalized, err := normalizeValue(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = normalized
+		}
+		return result, nil
+	case []interface{}:
+		if depth >= maxStateDepth {
+			return nil, fmt.Errorf("%w: nested deeper than %d levels", ErrInvalidState, maxStateDepth)
+		}
+		if value == nil {
+			return nil, nil
+		}
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			normalized, err := normalizeValue(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = normalized
+		}
+		return result, nil
+	default:
+		// Anything else goes through JSON to become maps, slices and scalars
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: value of type %T is not JSON-serializable", ErrInvalidState, value)
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidState, err)
+		}
+		return normalizeValue(decoded, depth)
+	}
+}
+
+// copyState deep-copies a state map so nested maps and slices aren't shared with callers
+func copyState(state map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(state))
+	for k, v := range state {
+		result[k] = copyValue(v)
+	}
+	return result
+}
+
+// copyValue deep-copies the JSON container types; other values are immutable
+func copyValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return copyState(value)
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = copyValue(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
@@ -0,0 +1,331 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStateManagerMerge(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxBytes    int
+		existing    map[string]interface{}
+		incoming    map[string]interface{}
+		want        map[string]interface{}
+		wantRemoved []string
+		wantErr     error
+	}{
+		{
+			name:     "new thread",
+			incoming: map[string]interface{}{"page": 1.0},
+			want:     map[string]interface{}{"page": 1.0},
+		},
+		{
+			name:     "server keys are kept",
+			existing: map[string]interface{}{"cart": "server"},
+			incoming: map[string]interface{}{"page": 2.0},
+			want:     map[string]interface{}{"cart": "server", "page": 2.0},
+		},
+		{
+			name:     "client wins on overlap",
+			existing: map[string]interface{}{"page": 1.0, "cart": "server"},
+			incoming: map[string]interface{}{"page": 2.0},
+			want:     map[string]interface{}{"page": 2.0, "cart": "server"},
+		},
+		{
+			name:     "nil incoming keeps server state",
+			existing: map[string]interface{}{"page": 1.0},
+			want:     map[string]interface{}{"page": 1.0},
+		},
+		{
+			name:        "reset removes keys before the overlay",
+			existing:    map[string]interface{}{"draft": "x", "filters": "y", "page": 1.0},
+			incoming:    map[string]interface{}{StateResetKey: []interface{}{"draft", "filters"}, "page": 2.0},
+			want:        map[string]interface{}{"page": 2.0},
+			wantRemoved: []string{"draft", "filters"},
+		},
+		{
+			name:        "reset then set the same key",
+			existing:    map[string]interface{}{"draft": "old"},
+			incoming:    map[string]interface{}{StateResetKey: []interface{}{"draft"}, "draft": "new"},
+			want:        map[string]interface{}{"draft": "new"},
+			wantRemoved: []string{"draft"},
+		},
+		{
+			name:     "reset ignores absent and non-string keys",
+			existing: map[string]interface{}{"page": 1.0},
+			incoming: map[string]interface{}{StateResetKey: []interface{}{"missing", 7.0}},
+			want:     map[string]interface{}{"page": 1.0},
+		},
+		{
+			name:     "merged state over the cap",
+			maxBytes: 20,
+			existing: map[string]interface{}{"a": "0123456789"},
+			incoming: map[string]interface{}{"b": "0123456789"},
+			want:     map[string]interface{}{"a": "0123456789"},
+			wantErr:  ErrStateTooLarge,
+		},
+		{
+			name:     "merged state at the cap",
+			maxBytes: len(`{"a":"x","b":"y"}`),
+			existing: map[string]interface{}{"a": "x"},
+			incoming: map[string]interface{}{"b": "y"},
+			want:     map[string]interface{}{"a": "x", "b": "y"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewStateManager(tt.maxBytes)
+			if tt.existing != nil {
+				if err := m.Set("thread-1", tt.existing); err != nil {
+					t.Fatalf("Set: %v", err)
+				}
+			}
+
+			merged, removed, err := m.Merge("thread-1", tt.incoming)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(merged, tt.want) {
+				t.Errorf("Merge = %v, want %v", merged, tt.want)
+			}
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("removed = %v, want %v", removed, tt.wantRemoved)
+			}
+			// A rejected merge leaves the stored state unchanged
+			if got := m.Get("thread-1"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stored state = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateManagerMergeCopies(t *testing.T) {
+	m := NewStateManager(0)
+	incoming := map[string]interface{}{
+		"filters": map[string]interface{}{"color": "red"},
+		"tags":    []interface{}{"a"},
+	}
+	merged, _, err := m.Merge("thread-1", incoming)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	// Neither the caller's input nor the returned state is shared with the stored state
+	incoming["filters"].(map[string]interface{})["color"] = "blue"
+	incoming["tags"].([]interface{})[0] = "b"
+	merged["filters"].(map[string]interface{})["size"] = "L"
+	merged["tags"] = append(merged["tags"].([]interface{}), "c")
+
+	got := m.Get("thread-1")
+	want := map[string]interface{}{
+		"filters": map[string]interface{}{"color": "red"},
+		"tags":    []interface{}{"a"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored state = %v, want %v", got, want)
+	}
+
+	// Nor is the state returned by Get
+	got["filters"].(map[string]interface{})["color"] = "green"
+	if color := m.Get("thread-1")["filters"].(map[string]interface{})["color"]; color != "red" {
+		t.Errorf("stored color = %v after mutating Get's result, want red", color)
+	}
+}
+
+func TestStateManagerRejectsOversizedState(t *testing.T) {
+	m := NewStateManager(16)
+	small := map[string]interface{}{"a": "x"}
+	large := map[string]interface{}{"a": strings.Repeat("x", 32)}
+	if err := m.Set("thread-1", small); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	before := m.Stats()
+
+	if err := m.Set("thread-1", large); !errors.Is(err, ErrStateTooLarge) {
+		t.Errorf("Set error = %v, want ErrStateTooLarge", err)
+	}
+	if _, _, err := m.Merge("thread-1", large); !errors.Is(err, ErrStateTooLarge) {
+		t.Errorf("Merge error = %v, want ErrStateTooLarge", err)
+	}
+	if _, _, err := m.Merge("thread-2", large); !errors.Is(err, ErrStateTooLarge) {
+		t.Errorf("Merge on a new thread error = %v, want ErrStateTooLarge", err)
+	}
+
+	if got := m.Get("thread-1"); !reflect.DeepEqual(got, small) {
+		t.Errorf("stored state = %v, want %v", got, small)
+	}
+	if _, ok := m.Lookup("thread-2"); ok {
+		t.Error("rejected state was stored for a new thread")
+	}
+	if after := m.Stats(); after != before {
+		t.Errorf("Stats = %+v after rejected writes, want %+v", after, before)
+	}
+}
+
+// nested returns a value nested depth levels deep, alternating objects and arrays
+func nested(depth int) interface{} {
+	var v interface{} = "leaf"
+	for i := 0; i < depth; i++ {
+		if i%2 == 0 {
+			v = []interface{}{v}
+		} else {
+			v = map[string]interface{}{"k": v}
+		}
+	}
+	return v
+}
+
+func TestNormalizeState(t *testing.T) {
+	type point struct {
+		X int `json:"x"`
+	}
+
+	tests := []struct {
+		name    string
+		state   map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:  "NaN and Inf become null",
+			state: map[string]interface{}{"nan": math.NaN(), "inf": math.Inf(1), "ninf": math.Inf(-1), "ok": 1.5},
+			want:  map[string]interface{}{"nan": nil, "inf": nil, "ninf": nil, "ok": 1.5},
+		},
+		{
+			name:  "nested NaN",
+			state: map[string]interface{}{"list": []interface{}{math.NaN(), map[string]interface{}{"v": float32(math.Inf(1))}}},
+			want:  map[string]interface{}{"list": []interface{}{nil, map[string]interface{}{"v": nil}}},
+		},
+		{
+			name:  "Go values go through JSON",
+			state: map[string]interface{}{"p": point{X: 3}},
+			want:  map[string]interface{}{"p": map[string]interface{}{"x": 3.0}},
+		},
+		{
+			name:  "at the depth limit",
+			state: map[string]interface{}{"v": nested(maxStateDepth - 1)},
+			want:  map[string]interface{}{"v": nested(maxStateDepth - 1)},
+		},
+		{
+			name:    "beyond the depth limit",
+			state:   map[string]interface{}{"v": nested(maxStateDepth)},
+			wantErr: true,
+		},
+		{
+			name:    "not JSON-serializable",
+			state:   map[string]interface{}{"ch": make(chan int)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeState(tt.state)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidState) {
+					t.Fatalf("NormalizeState error = %v, want ErrInvalidState", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeState: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeState = %v, want %v", got, tt.want)
+			}
+			if _, err := json.Marshal(got); err != nil {
+				t.Errorf("normalized state doesn't marshal: %v", err)
+			}
+		})
+	}
+}
+
+func TestMergeNormalizesState(t *testing.T) {
+	m := NewStateManager(0)
+	merged, _, err := m.Merge("thread-1", map[string]interface{}{"score": math.NaN()})
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if v, ok := merged["score"]; !ok || v != nil {
+		t.Errorf("merged score = %v, want null", v)
+	}
+
+	if _, _, err := m.Merge("thread-1", map[string]interface{}{"deep": nested(maxStateDepth)}); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Merge of deep state error = %v, want ErrInvalidState", err)
+	}
+	if _, ok := m.Get("thread-1")["deep"]; ok {
+		t.Error("rejected state was stored")
+	}
+}
+
+func TestStateManagerConcurrentAccess(t *testing.T) {
+	m := NewStateManager(0)
+	const goroutines = 16
+	const iterations = 200
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				switch (g + i) % 5 {
+				case 0:
+					merged, _, err := m.Merge("thread-1", map[string]interface{}{
+						"count":  float64(i),
+						"nested": map[string]interface{}{"tags": []interface{}{"a", float64(g)}},
+					})
+					if err != nil {
+						t.Errorf("Merge: %v", err)
+						return
+					}
+					// The caller owns the merged state: changing it must not race with other readers
+					merged["nested"].(map[string]interface{})["tags"].([]interface{})[0] = "mine"
+				case 1:
+					if err := m.Set("thread-1", map[string]interface{}{"nested": map[string]interface{}{"set": float64(g)}}); err != nil {
+						t.Errorf("Set: %v", err)
+						return
+					}
+				case 2:
+					// The state from Get is a copy: mutating it must not race either
+					state := m.Get("thread-1")
+					if nested, ok := state["nested"].(map[string]interface{}); ok {
+						nested["seen"] = true
+					}
+				case 3:
+					m.Delete("thread-1")
+				case 4:
+					if _, _, err := m.Merge("thread-1", map[string]interface{}{StateResetKey: []interface{}{"count"}}); err != nil {
+						t.Errorf("Merge reset: %v", err)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// The size accounting stays consistent with what is stored
+	stats := m.Stats()
+	state, ok := m.Lookup("thread-1")
+	if !ok {
+		if stats.Threads != 0 || stats.Bytes != 0 {
+			t.Errorf("Stats = %+v with no state stored", stats)
+		}
+		return
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("stored state doesn't marshal: %v", err)
+	}
+	if stats.Threads != 1 || stats.Bytes != len(data) {
+		t.Errorf("Stats = %+v, want 1 thread of %d bytes", stats, len(data))
+	}
+}
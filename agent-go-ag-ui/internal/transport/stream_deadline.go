@@ -0,0 +1,28 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrStreamDurationExceeded is the cause of a stream context that hit MAX_STREAM_DURATION
+// It tells the hard stream cap apart from the agent timeout
+var ErrStreamDurationExceeded = errors.New("stream duration exceeded")
+
+// ErrorCodeStreamDurationExceeded is the RUN_ERROR code of streams closed by the duration cap
+const ErrorCodeStreamDurationExceeded = "stream_duration_exceeded"
+
+// WithStreamDeadline bounds a stream's context by maxDuration (0 = unlimited)
+// The context's cause is ErrStreamDurationExceeded once the cap is hit
+func WithStreamDeadline(ctx context.Context, maxDuration time.Duration) (context.Context, context.CancelFunc) {
+	if maxDuration <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, maxDuration, ErrStreamDurationExceeded)
+}
+
+// StreamDurationExceeded reports whether ctx ended because its stream hit the duration cap
+func StreamDurationExceeded(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrStreamDurationExceeded)
+}
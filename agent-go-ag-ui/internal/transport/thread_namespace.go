@@ -0,0 +1,29 @@
+package transport
+
+import "context"
+
+// threadNamespaceKey is the context key for the namespace of the request's thread IDs
+type threadNamespaceKey struct{}
+
+// WithThreadNamespace returns a context whose thread IDs are kept apart from other namespaces
+func WithThreadNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, threadNamespaceKey{}, namespace)
+}
+
+// ThreadNamespaceFromContext returns the namespace of the request's thread IDs, or "" if none is set
+func ThreadNamespaceFromContext(ctx context.Context) string {
+	namespace, _ := ctx.Value(threadNamespaceKey{}).(string)
+	return namespace
+}
+
+// ThreadKey returns the internal key of a client thread ID: the ID prefixed with the request's
+// namespace, so equal IDs from different tenants don't share a session, state, run or replay
+// The separator is "/", which thread IDs may not contain, so no namespace and thread ID pair
+// can produce another's key
+// Clients only ever see their own thread IDs
+func ThreadKey(ctx context.Context, threadID string) string {
+	if namespace := ThreadNamespaceFromContext(ctx); namespace != "" {
+		return namespace + "/" + threadID
+	}
+	return threadID
+}
@@ -0,0 +1,31 @@
+package transport
+
+import (
+	"context"
+	"testing"
+)
+
+func TestThreadKeyKeepsNamespacesApart(t *testing.T) {
+	key := func(namespace, threadID string) string {
+		ctx := context.Background()
+		if namespace != "" {
+			ctx = WithThreadNamespace(ctx, namespace)
+		}
+		return ThreadKey(ctx, threadID)
+	}
+
+	// Thread IDs may contain ":", so it must not be able to shift the namespace boundary
+	pairs := [][2][2]string{
+		{{"a", "b:c"}, {"a:b", "c"}},
+		{{"", "tenant:thread"}, {"tenant", "thread"}},
+		{{"tenant-a", "thread-1"}, {"tenant-b", "thread-1"}},
+		{{"", "thread-1"}, {"tenant-a", "thread-1"}},
+	}
+	for _, pair := range pairs {
+		first, second := pair[0], pair[1]
+		if key(first[0], first[1]) == key(second[0], second[1]) {
+			t.Errorf("namespace %q thread %q and namespace %q thread %q share key %q",
+				first[0], first[1], second[0], second[1], key(first[0], first[1]))
+		}
+	}
+}